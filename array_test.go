@@ -1,15 +1,16 @@
-package main
+package javaserializer
 
 import "testing"
 import "os"
+import "bytes"
+import "errors"
+import "reflect"
 
 func TestJavaTcArray(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.Open("d:\\tmp\\serialize-child.data"); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = openTestData(t, "serialize-child.data")
 	defer f.Close()
 	arr := make([]byte, 1<<7) //128
 
@@ -17,7 +18,7 @@ func TestJavaTcArray(t *testing.T) {
 		//to be continued...
 		t.Fatalf("Got error %v\n", err)
 	}
-	refs := make([]*JavaReferenceObject, 1<<7)
+	refs := NewJavaReferencePool(1 << 7)
 	jarr := &JavaTcArray{}
 	if err = jarr.Deserialize(f, refs); err != nil {
 		t.Fatalf("When deserialize JavaTcArray got %v\n", err)
@@ -42,9 +43,7 @@ func TestArraySerialize(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.OpenFile("d:\\tmp\\serialize-go-arr.data", os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = createTestData(t, "serialize-go-arr.data")
 	defer f.Close()
 
 	if err = SerializeJavaEntity(f, jArr); err != nil {
@@ -69,9 +68,7 @@ func TestStringArraySerialize(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.OpenFile("d:\\tmp\\serialize-go-arr-1.data", os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = createTestData(t, "serialize-go-arr-1.data")
 	defer f.Close()
 
 	if err = SerializeJavaEntity(f, jArr); err != nil {
@@ -81,3 +78,78 @@ func TestStringArraySerialize(t *testing.T) {
 	}
 
 }
+
+func TestPrimArrays(t *testing.T) {
+	arrays := []*JavaTcArray{
+		NewBooleanArray([]bool{true}),
+		NewByteArray([]byte{0xFF}),
+		NewCharArray([]uint16{'c'}),
+		NewShortArray([]int16{-2}),
+		NewIntArray([]int32{-3}),
+		NewLongArray([]int64{-4}),
+		NewFloatArray([]float32{0.5}),
+		NewDoubleArray([]float64{0.25}),
+	}
+	for _, arr := range arrays {
+		className := arr.ClassDesc.ClassName
+		if arr.SerialVersionUID != ArraySerialVersionUID(className) {
+			t.Fatalf("Unexpected serialVersionUID 0x%X of %s\n", arr.SerialVersionUID, className)
+		}
+		out := new(bytes.Buffer)
+		if err := SerializeJavaEntity(out, arr); err != nil {
+			t.Fatalf("SerializeJavaEntity %s got %v\n", className, err)
+		}
+		var got *JavaTcArray
+		if err := Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("Unmarshal %s got %v\n", className, err)
+		}
+		if !reflect.DeepEqual(got.Prims, arr.Prims) {
+			t.Fatalf("Expect %v, but got %v\n", arr.Prims, got.Prims)
+		}
+	}
+
+	//Values按ClassName的元素类型写, int in long[] is 8 bytes
+	longs := NewJavaTcArray(SID_LONG_ARRAY)
+	longs.ClassDesc = NewJavaTcClassDesc("[J", SID_LONG_ARRAY, SC_SERIALIZABLE)
+	longs.Values = append(longs.Values, 1, uint32(2), int64(-3))
+	out := new(bytes.Buffer)
+	if err := SerializeJavaEntity(out, longs); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	var got []int64
+	if err := Unmarshal(out.Bytes(), &got); err != nil || !reflect.DeepEqual(got, []int64{1, 2, -3}) {
+		t.Fatalf("Expect [1 2 -3], but got %v %v\n", got, err)
+	}
+
+	//mismatches are errors, nothing is written
+	bad := NewByteArray(nil)
+	bad.Prims = nil
+	bad.Values = []interface{}{300}
+	mismatch := NewLongArray(nil)
+	mismatch.Prims = []int32{1}
+	for _, arr := range []*JavaTcArray{bad, mismatch} {
+		out := new(bytes.Buffer)
+		if err := arr.Serialize(out, NewJavaReferencePool(8)); err == nil || out.Len() != 0 {
+			t.Fatalf("Expect error of %v, but got %v and %d bytes\n", arr, err, out.Len())
+		}
+	}
+}
+
+func TestIllegalArrayClassName(t *testing.T) {
+	for _, name := range []string{"[", "[L", "[Lfoo", "B["} {
+		data := javaStream(TC_ARRAY, TC_CLASSDESC, 0x00, len(name), name, make([]byte, 8), SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+			[]byte{0x00, 0x00, 0x00, 0x01}, TC_NULL)
+		var js JavaSerializer
+		if err := Unmarshal(data, &js); !errors.Is(err, ErrBadReference) {
+			t.Fatalf("Expect ErrBadReference for array class name %q, but got %v\n", name, err)
+		}
+	}
+	//field of object type without class name
+	obj := NewJavaTcObject(1)
+	clz := NewJavaTcClassDesc("Holder", 1, SC_SERIALIZABLE)
+	clz.Fields = append(clz.Fields, NewObjectJavaField("", "value", nil))
+	obj.Classes = append(obj.Classes, clz)
+	if err := SerializeJavaEntity(new(bytes.Buffer), obj); err == nil {
+		t.Fatalf("Expect error for empty field class name\n")
+	}
+}
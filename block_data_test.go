@@ -0,0 +1,102 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "io"
+
+func TestBlockDataRoundTrip(t *testing.T) {
+	out := new(bytes.Buffer)
+	sw := NewJavaStreamWriter(out)
+	bw, err := sw.BlockData()
+	if err != nil {
+		t.Fatalf("BlockData got %v\n", err)
+	}
+	//1020字节后的long会被拆在二个block中
+	bw.Write(make([]byte, 1020))
+	bw.WriteLong(-2)
+	bw.WriteUTF("中\x00文")
+	bw.WriteBoolean(true)
+	bw.WriteDouble(1.5)
+	bw.WriteFloat(-0.25)
+	bw.WriteChar('c')
+	bw.WriteShort(-3)
+	if err = sw.WriteEntity(NewJavaTcString("a")); err != nil {
+		t.Fatalf("WriteEntity got %v\n", err)
+	}
+	bw.WriteInt(7)
+	if err = sw.Reset(); err != nil {
+		t.Fatalf("Reset got %v\n", err)
+	}
+	bw.WriteInt(8)
+	if err = sw.Flush(); err != nil {
+		t.Fatalf("Flush got %v\n", err)
+	}
+	data := out.Bytes()
+	if data[4] != TC_BLOCKDATALONG || !bytes.Equal(data[5:9], []byte{0x00, 0x00, 0x04, 0x00}) {
+		t.Fatalf("Expect TC_BLOCKDATALONG of 1024 bytes, but got %x\n", data[4:9])
+	}
+
+	sr := NewJavaStreamReader(bytes.NewReader(data))
+	br, err := sr.BlockData()
+	if err != nil {
+		t.Fatalf("BlockData got %v\n", err)
+	}
+	if err = br.ReadFully(make([]byte, 1020)); err != nil {
+		t.Fatalf("ReadFully got %v\n", err)
+	}
+	if v, err := br.ReadLong(); err != nil || v != -2 {
+		t.Fatalf("ReadLong got %v %v\n", v, err)
+	}
+	if v, err := br.ReadUTF(); err != nil || v != "中\x00文" {
+		t.Fatalf("ReadUTF got %q %v\n", v, err)
+	}
+	if v, err := br.ReadBoolean(); err != nil || !v {
+		t.Fatalf("ReadBoolean got %v %v\n", v, err)
+	}
+	if v, err := br.ReadDouble(); err != nil || v != 1.5 {
+		t.Fatalf("ReadDouble got %v %v\n", v, err)
+	}
+	if v, err := br.ReadFloat(); err != nil || v != -0.25 {
+		t.Fatalf("ReadFloat got %v %v\n", v, err)
+	}
+	if v, err := br.ReadChar(); err != nil || v != 'c' {
+		t.Fatalf("ReadChar got %v %v\n", v, err)
+	}
+	if v, err := br.ReadShort(); err != nil || v != -3 {
+		t.Fatalf("ReadShort got %v %v\n", v, err)
+	}
+	//the object after block data
+	if _, err = br.ReadInt(); err != io.EOF {
+		t.Fatalf("Expect io.EOF before the object, but got %v\n", err)
+	}
+	if content, err := sr.ReadContent(); err != nil || content.Kind != TC_STRING {
+		t.Fatalf("ReadContent got %v %v\n", content, err)
+	}
+	//TC_RESET between the blocks
+	if v, err := br.ReadInt(); err != nil || v != 7 {
+		t.Fatalf("ReadInt got %v %v\n", v, err)
+	}
+	if v, err := br.ReadInt(); err != nil || v != 8 {
+		t.Fatalf("ReadInt got %v %v\n", v, err)
+	}
+	if _, err = br.ReadInt(); err != io.EOF {
+		t.Fatalf("Expect io.EOF, but got %v\n", err)
+	}
+}
+
+func TestBlockDataRemainContent(t *testing.T) {
+	data := javaStream(TC_BLOCKDATA, 0x06, []byte{0x00, 0x00, 0x00, 0x09, 0x0A, 0x0B}, TC_NULL)
+	sr := NewJavaStreamReader(bytes.NewReader(data))
+	br, _ := sr.BlockData()
+	if v, err := br.ReadInt(); err != nil || v != 9 {
+		t.Fatalf("ReadInt got %v %v\n", v, err)
+	}
+	if content, err := sr.ReadContent(); err != nil {
+		t.Fatalf("ReadContent got %v\n", err)
+	} else if content.Kind != TC_BLOCKDATA || !bytes.Equal(content.Data, []byte{0x0A, 0x0B}) {
+		t.Fatalf("Expect the rest of the block, but got 0x%x %x\n", content.Kind, content.Data)
+	}
+	if content, err := sr.ReadContent(); err != nil || content.Kind != TC_NULL {
+		t.Fatalf("Expect TC_NULL, but got %v %v\n", content, err)
+	}
+}
@@ -0,0 +1,87 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+
+func TestClassField(t *testing.T) {
+	//class Holder { Class<?> type = String.class; Class<?> other = String.class; }
+	data := javaStream(
+		TC_OBJECT,
+		TC_CLASSDESC, 0x00, 0x06, "Holder", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x02,
+		TC_OBJ_OBJECT, 0x00, 0x04, "type", TC_STRING, 0x00, 0x11, "Ljava/lang/Class;",
+		TC_OBJ_OBJECT, 0x00, 0x05, "other", TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x01},
+		TC_ENDBLOCKDATA, TC_NULL,
+		TC_CLASS,
+		TC_CLASSDESC, 0x00, 0x10, "java.lang.String", []byte{0xA0, 0xF0, 0xA4, 0x38, 0x7A, 0x3B, 0xB3, 0x42}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA,
+		TC_NULL,
+		TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x04},
+	)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	mp := v.JsonMap().(map[string]interface{})
+	if mp["type"] != "java.lang.String" || mp["other"] != "java.lang.String" {
+		t.Fatalf("Unexpected json map %v\n", mp)
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
+
+func TestPrimClass(t *testing.T) {
+	//int.class
+	data := javaStream(TC_CLASS, TC_CLASSDESC, 0x00, 0x03, "int", make([]byte, 8), 0x00, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	if v.JsonMap() != "int" {
+		t.Fatalf("Expect int, but got %v\n", v.JsonMap())
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, NewJavaTcClass("int", 0, 0x00)); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
+
+func TestClassSameClassDesc(t *testing.T) {
+	//Object[] { String.class, String.class }, the second TC_CLASS refers to the classDesc of the first
+	data := javaStream(
+		TC_ARRAY,
+		TC_CLASSDESC, 0x00, 0x13, "[Ljava.lang.Object;", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x00, 0x00, 0x00, 0x02},
+		TC_CLASS,
+		TC_CLASSDESC, 0x00, 0x10, "java.lang.String", []byte{0xA0, 0xF0, 0xA4, 0x38, 0x7A, 0x3B, 0xB3, 0x42}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA,
+		TC_NULL,
+		TC_CLASS, TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x02},
+	)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	tcArr := v.(*JavaTcArray)
+	first, second := tcArr.Values[0].(*JavaTcClass), tcArr.Values[1].(*JavaTcClass)
+	if first == second || first.ClassDesc != second.ClassDesc || second.JsonMap() != "java.lang.String" {
+		t.Fatalf("Unexpected classes %v %v\n", first, second)
+	}
+	//one java.lang.Class per class, the second is written as TC_REFERENCE to the first
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if v, err = DeserializeStream(bytes.NewReader(out.Bytes())); err != nil {
+		t.Fatalf("DeserializeStream %x got %v\n", out.Bytes(), err)
+	}
+	tcArr = v.(*JavaTcArray)
+	if tcArr.Values[0] != tcArr.Values[1] || tcArr.Values[1].(*JavaTcClass).JsonMap() != "java.lang.String" {
+		t.Fatalf("Unexpected classes %v\n", tcArr.Values)
+	}
+}
@@ -0,0 +1,246 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "bufio"
+
+//DEFAULT_MAX_DEPTH default max nesting depth of objects & arrays, the same as encoding/json
+const DEFAULT_MAX_DEPTH = 10000
+
+//codecContext options & state of one Decoder or Encoder
+//it travels with the handle table, so every Deserialize & Serialize can reach it through refs
+type codecContext struct {
+	strict   bool            //strict: unexpected data is an error; lenient: log it and go on if possible
+	maxDepth int             //max nesting depth of objects & arrays, <= 0 means no limit
+	depth    int             //current nesting depth
+	log      *Logger         //logger of this codec only, it keeps the nesting depth of the log records
+	types    *TypeRegistry   //registry of the class handlers
+	path     []pathSegment   //path of the value being decoded, see DecodeError
+	in       *CountingReader //the stream being decoded, for the offset of DecodeError
+}
+
+func newCodecContext() *codecContext {
+	return &codecContext{
+		maxDepth: DEFAULT_MAX_DEPTH,
+		log:      &Logger{},
+		types:    DefaultTypeRegistry,
+	}
+}
+
+//context return the codec context, the pools not owned by Decoder or Encoder use the default options
+func (pool *JavaReferencePool) context() *codecContext {
+	if pool.ctx == nil {
+		pool.ctx = newCodecContext()
+	}
+	return pool.ctx
+}
+
+//logger return the logger of the Decoder or Encoder
+func (pool *JavaReferencePool) logger() *Logger {
+	return pool.context().log
+}
+
+//registry return the type registry of the Decoder or Encoder
+func (pool *JavaReferencePool) registry() *TypeRegistry {
+	return pool.context().types
+}
+
+//strict judge if unexpected data should be an error
+func (pool *JavaReferencePool) strict() bool {
+	return pool.context().strict
+}
+
+//enter go into a nested object or array, error if it is too deep
+func (pool *JavaReferencePool) enter() error {
+	ctx := pool.context()
+	if ctx.maxDepth > 0 && ctx.depth >= ctx.maxDepth {
+		return fmt.Errorf("Exceeded max depth %d", ctx.maxDepth)
+	}
+	ctx.depth++
+	return nil
+}
+
+//leave come out of the nested object or array
+func (pool *JavaReferencePool) leave() {
+	pool.context().depth--
+}
+
+//Decoder read java contents from a stream one by one, like encoding/json.Decoder
+//it owns the handle table, the buffering and the options, handles are shared between the contents until TC_RESET
+//the Decoder may read data from r beyond the contents requested
+type Decoder struct {
+	sr  *JavaStreamReader
+	err error //error of the stream header
+}
+
+//NewDecoder new decoder reading from r, the default options are lenient, DEFAULT_MAX_DEPTH, no logging and DefaultTypeRegistry
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		sr: NewJavaStreamReader(r),
+	}
+}
+
+//NewBytesDecoder new decoder parsing the stream data in memory without copying, the options are the same as NewDecoder
+//block data, byte[] and the bytes of the other primitive arrays are read from data in place, byte[] & block data alias data
+func NewBytesDecoder(data []byte) *Decoder {
+	return &Decoder{
+		sr: newJavaStreamReader(newBytesCountingReader(data)),
+	}
+}
+
+//SetStrict strict decoder returns error for the unexpected data which the lenient one only logs, e.g. unknown SC_FLAG
+func (dec *Decoder) SetStrict(strict bool) {
+	dec.sr.refs.context().strict = strict
+}
+
+//SetMaxDepth set the max nesting depth of objects & arrays, <= 0 means no limit
+func (dec *Decoder) SetMaxDepth(depth int) {
+	dec.sr.refs.context().maxDepth = depth
+}
+
+//SetLogger set the handler of the log records, nil to turn logging off
+func (dec *Decoder) SetLogger(handler LogHandler) {
+	dec.sr.refs.context().log = NewLogger(handler)
+}
+
+//SetRegistry set the type registry consulted for the class handlers
+func (dec *Decoder) SetRegistry(registry *TypeRegistry) {
+	dec.sr.refs.context().types = registry
+}
+
+//More judge if there is another content in the stream
+func (dec *Decoder) More() bool {
+	if dec.err == nil && !dec.sr.headerRead {
+		dec.err = dec.sr.readHeader()
+	}
+	if dec.err != nil {
+		//留给Decode返回
+		return true
+	}
+	if dec.sr.block.remain > 0 || dec.sr.block.hasPeek {
+		return true
+	}
+	//TC_RESET 之后可能已没有content
+	for i := 1; ; i++ {
+		bs, err := dec.sr.reader.Peek(i)
+		if err != nil {
+			return false
+		}
+		if bs[i-1] != TC_RESET {
+			return true
+		}
+	}
+}
+
+//Decode read next content and store it in the value pointed to by v, io.EOF if there is no more content
+//v may be *JavaContent, *[]byte for block data, *interface{} for the json style data or the registered GoType (block data as []byte),
+//or any other pointer accepted by Unmarshal, e.g. *JavaSerializer, **JavaTcObject, *struct
+//TC_NULL stores the zero value
+//the strict decoder returns error for the java fields not found in the struct
+func (dec *Decoder) Decode(v interface{}) error {
+	if dec.err != nil {
+		return dec.err
+	}
+	content, err := dec.sr.ReadContent()
+	if err != nil {
+		return err
+	}
+	switch p := v.(type) {
+	case *JavaContent:
+		*p = *content
+		return nil
+	case *[]byte:
+		if content.Kind != TC_BLOCKDATA {
+			return fmt.Errorf("Cannot decode content 0x%x into *[]byte", content.Kind)
+		}
+		*p = content.Data
+		return nil
+	case *interface{}:
+		//entity 由unmarshalEntity 转为JsonMap 或注册的GoType
+		if content.Kind == TC_BLOCKDATA {
+			*p = content.Data
+			return nil
+		}
+	}
+	if content.Kind == TC_BLOCKDATA {
+		return fmt.Errorf("Cannot decode block data into %T", v)
+	}
+	return unmarshalEntity(content.Entity, v, dec.sr.refs.strict(), dec.sr.refs.registry())
+}
+
+//Encoder write java contents to a stream one by one, like encoding/json.Encoder
+//it owns the handle table, the buffering and the options, handles are shared between the contents until Reset
+type Encoder struct {
+	sw  *JavaStreamWriter
+	buf *bufio.Writer
+}
+
+//NewEncoder new encoder writing to w, the default options are lenient, DEFAULT_MAX_DEPTH, no logging and DefaultTypeRegistry
+func NewEncoder(w io.Writer) *Encoder {
+	buf := bufio.NewWriter(w)
+	return &Encoder{
+		sw:  NewJavaStreamWriter(buf),
+		buf: buf,
+	}
+}
+
+//SetStrict strict encoder returns error for the data which the lenient one coerces, e.g. invalid UTF-8 in strings & names
+func (enc *Encoder) SetStrict(strict bool) {
+	enc.sw.refs.context().strict = strict
+}
+
+//SetMaxDepth set the max nesting depth of objects & arrays, <= 0 means no limit
+func (enc *Encoder) SetMaxDepth(depth int) {
+	enc.sw.refs.context().maxDepth = depth
+}
+
+//SetLogger set the handler of the log records, nil to turn logging off
+func (enc *Encoder) SetLogger(handler LogHandler) {
+	enc.sw.refs.context().log = NewLogger(handler)
+}
+
+//SetRegistry set the type registry consulted for the class handlers
+func (enc *Encoder) SetRegistry(registry *TypeRegistry) {
+	enc.sw.refs.context().types = registry
+}
+
+//Encode write v as the next content, the stream header is written before the first content
+//v may be JavaSerializer, string, []byte as block data, nil as TC_NULL, or any value Marshal accepts
+func (enc *Encoder) Encode(v interface{}) error {
+	var err error
+	switch x := v.(type) {
+	case nil:
+		if err = enc.sw.Flush(); err == nil {
+			_, err = enc.sw.writer.Write([]byte{TC_NULL})
+		}
+	case []byte:
+		var bw *JavaBlockDataWriter
+		if bw, err = enc.sw.BlockData(); err == nil {
+			if _, err = bw.Write(x); err == nil {
+				err = enc.sw.Flush()
+			}
+		}
+	case string:
+		err = enc.sw.WriteEntity(NewJavaTcString(x))
+	case JavaSerializer:
+		err = enc.sw.WriteEntity(x)
+	default:
+		//java-tagged struct etc., see Marshal
+		var js JavaSerializer
+		if js, err = marshalEntity(v, enc.sw.refs.registry()); err == nil {
+			err = enc.sw.WriteEntity(js)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return enc.buf.Flush()
+}
+
+//Reset write TC_RESET, the contents encoded before will not be referenced any more
+func (enc *Encoder) Reset() error {
+	if err := enc.sw.Reset(); err != nil {
+		return err
+	}
+	return enc.buf.Flush()
+}
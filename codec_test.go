@@ -0,0 +1,218 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "io"
+import "reflect"
+import "encoding/json"
+import "errors"
+import "runtime"
+
+func TestEncoderDecoder(t *testing.T) {
+	out := new(bytes.Buffer)
+	enc := NewEncoder(out)
+	arr := NewByteArray([]byte{0x01, 0x02})
+	for _, v := range []interface{}{"a", arr, arr, []byte{0x09}, nil} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("Encode %v got %v\n", v, err)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader(out.Bytes()))
+	var str interface{}
+	if err := dec.Decode(&str); err != nil || str != JavaTcString("a") {
+		t.Fatalf("Expect a, but got %v %v\n", str, err)
+	}
+	var a1, a2 *JavaTcArray
+	if err := dec.Decode(&a1); err != nil {
+		t.Fatalf("Decode array got %v\n", err)
+	}
+	if err := dec.Decode(&a2); err != nil {
+		t.Fatalf("Decode array got %v\n", err)
+	}
+	if a1 != a2 || !reflect.DeepEqual(a1.Prims, []byte{0x01, 0x02}) {
+		t.Fatalf("Expect the same array [1 2], but got %v %v\n", a1, a2)
+	}
+	var data []byte
+	if err := dec.Decode(&data); err != nil || !bytes.Equal(data, []byte{0x09}) {
+		t.Fatalf("Expect block data [9], but got %v %v\n", data, err)
+	}
+	var js JavaSerializer = a1
+	if err := dec.Decode(&js); err != nil || js != nil {
+		t.Fatalf("Expect TC_NULL, but got %v %v\n", js, err)
+	}
+	if dec.More() {
+		t.Fatalf("Expect no more content\n")
+	}
+	if err := dec.Decode(&js); err != io.EOF {
+		t.Fatalf("Expect io.EOF, but got %v\n", err)
+	}
+}
+
+func TestDecoderOptions(t *testing.T) {
+	//byte[][] {{1}}
+	nested := javaStream(
+		TC_ARRAY, TC_CLASSDESC, 0x00, 0x03, "[[B", make([]byte, 8), SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x00, 0x00, 0x00, 0x01},
+		TC_ARRAY, TC_CLASSDESC, 0x00, 0x02, "[B", make([]byte, 8), SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x00, 0x00, 0x00, 0x01}, 0x01,
+	)
+	dec := NewDecoder(bytes.NewReader(nested))
+	dec.SetMaxDepth(1)
+	var js JavaSerializer
+	if err := dec.Decode(&js); err == nil {
+		t.Fatalf("Expect error for max depth 1\n")
+	}
+	dec = NewDecoder(bytes.NewReader(nested))
+	dec.SetMaxDepth(2)
+	if err := dec.Decode(&js); err != nil {
+		t.Fatalf("Decode with max depth 2 got %v\n", err)
+	}
+
+	//class Base is not serializable, it has no classdata
+	base := javaStream(
+		TC_OBJECT, TC_CLASSDESC, 0x00, 0x04, "Base", make([]byte, 8), 0x00, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+	)
+	dec = NewDecoder(bytes.NewReader(base))
+	if err := dec.Decode(&js); err != nil {
+		t.Fatalf("Lenient decode got %v\n", err)
+	}
+	dec = NewDecoder(bytes.NewReader(base))
+	dec.SetStrict(true)
+	if err := dec.Decode(&js); err == nil {
+		t.Fatalf("Expect error for unexpected SC_FLAG in strict mode\n")
+	}
+}
+
+func TestEncoderStrict(t *testing.T) {
+	//invalid UTF-8 is written as U+FFFD by the lenient encoder
+	out := new(bytes.Buffer)
+	if err := NewEncoder(out).Encode("a\xffb"); err != nil {
+		t.Fatalf("Lenient encode got %v\n", err)
+	}
+	var str string
+	if err := Unmarshal(out.Bytes(), &str); err != nil || str != "a\uFFFDb" {
+		t.Fatalf("Expect a\uFFFDb, but got %q %v\n", str, err)
+	}
+	enc := NewEncoder(new(bytes.Buffer))
+	enc.SetStrict(true)
+	if err := enc.Encode("a\xffb"); err == nil {
+		t.Fatalf("Expect error for invalid UTF-8 in strict mode\n")
+	}
+	if err := enc.Encode(NewJavaTcClass("Bad\xff", 1, SC_SERIALIZABLE)); err == nil {
+		t.Fatalf("Expect error for invalid UTF-8 class name in strict mode\n")
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	classNames := []string{"[Z", "[B", "[C", "[S", "[I", "[J", "[F", "[D"}
+	prims := []interface{}{
+		[]bool{true, false},
+		[]byte{0x01, 0xFF},
+		[]uint16{'a', 0xFFFF},
+		[]int16{-1, 2},
+		[]int32{-1, 1 << 30},
+		[]int64{-1, 1 << 40},
+		[]float32{1.5, -2},
+		[]float64{0.25, -3},
+	}
+	out := new(bytes.Buffer)
+	enc := NewEncoder(out)
+	for i, p := range prims {
+		className := classNames[i]
+		arr := &JavaTcArray{ClassDesc: NewJavaTcClassDesc(className, ArraySerialVersionUID(className), SC_SERIALIZABLE), Prims: p}
+		if err := enc.Encode(arr); err != nil {
+			t.Fatalf("Encode %s got %v\n", className, err)
+		}
+	}
+	data := out.Bytes()
+	dec := NewBytesDecoder(data)
+	for _, p := range prims {
+		var arr *JavaTcArray
+		if err := dec.Decode(&arr); err != nil {
+			t.Fatalf("Decode got %v\n", err)
+		}
+		if !reflect.DeepEqual(arr.Prims, p) {
+			t.Fatalf("Expect %v, but got %v\n", p, arr.Prims)
+		}
+		//json data 仍与 Values 相同, byte[] 为数字数组
+		if js, err := json.Marshal(arr.JsonMap()); err != nil || js[0] != '[' {
+			t.Fatalf("Expect json array of %v, but got %s %v\n", p, js, err)
+		}
+		//byte[] 直接引用data
+		if bs, ok := arr.Prims.([]byte); ok && &bs[0] != &data[bytes.Index(data, p.([]byte))] {
+			t.Fatalf("Expect byte[] aliases the data\n")
+		}
+	}
+
+	var ints []int32
+	if err := DecodeBytes(data[:0], &ints); err != io.EOF {
+		t.Fatalf("Expect io.EOF, but got %v\n", err)
+	}
+	//the allocations do not grow with the elements
+	allocs := func(n int) float64 {
+		out := new(bytes.Buffer)
+		arr := &JavaTcArray{ClassDesc: NewJavaTcClassDesc("[J", ArraySerialVersionUID("[J"), SC_SERIALIZABLE), Prims: make([]int64, n)}
+		if err := NewEncoder(out).Encode(arr); err != nil {
+			t.Fatalf("Encode got %v\n", err)
+		}
+		var js JavaSerializer
+		return testing.AllocsPerRun(10, func() {
+			if err := DecodeBytes(out.Bytes(), &js); err != nil {
+				t.Fatalf("DecodeBytes got %v\n", err)
+			}
+		})
+	}
+	if small, large := allocs(1000), allocs(100000); small != large {
+		t.Fatalf("Expect the same allocations, but got %v for 1000 elements, %v for 100000\n", small, large)
+	}
+}
+
+func TestDecodeArraySize(t *testing.T) {
+	header := []interface{}{TC_ARRAY, TC_CLASSDESC, 0x00, 0x02, "[J", make([]byte, 8), SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL}
+	//负数长度
+	negative := javaStream(append(header, []byte{0xFF, 0xFF, 0xFF, 0xFF}, make([]byte, 8))...)
+	var js JavaSerializer
+	var de *DecodeError
+	if err := Unmarshal(negative, &js); !errors.As(err, &de) {
+		t.Fatalf("Expect *DecodeError of the size, but got %v\n", err)
+	}
+	if err := DecodeBytes(negative, &js); !errors.As(err, &de) {
+		t.Fatalf("Expect *DecodeError of the size, but got %v\n", err)
+	}
+	//2GB long[] with 8 bytes only, the array is not allocated before the data
+	truncated := javaStream(append(header, []byte{0x10, 0x00, 0x00, 0x00}, make([]byte, 8))...)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := Unmarshal(truncated, &js); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+	if err := DecodeBytes(truncated, &js); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+	//Object[] & ArrayList of MaxInt32 elements with one element only
+	objects := javaStream(TC_ARRAY, TC_CLASSDESC, 0x00, 0x13, "[Ljava.lang.Object;", make([]byte, 8), SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x7F, 0xFF, 0xFF, 0xFF}, TC_NULL)
+	if err := Unmarshal(objects, &js); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+	if err := Unmarshal(arrayListOfSize([]byte{0x7F, 0xFF, 0xFF, 0xFF}), &js); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<24 {
+		t.Fatalf("Expect no allocation of the whole array, but got %d bytes\n", allocated)
+	}
+	if err := Unmarshal(arrayListOfSize([]byte{0xFF, 0xFF, 0xFF, 0xFF}), &js); !errors.As(err, &de) {
+		t.Fatalf("Expect *DecodeError of the size, but got %v\n", err)
+	}
+}
+
+//arrayListOfSize ArrayList of the size with one null element
+func arrayListOfSize(size []byte) []byte {
+	return javaStream(
+		TC_OBJECT, TC_CLASSDESC, 0x00, 0x13, "java.util.ArrayList", []byte{0x78, 0x81, 0xD2, 0x1D, 0x99, 0xC7, 0x61, 0x9D}, SC_RW_OBJECT, 0x00, 0x01,
+		TC_PRIM_INTEGER, 0x00, 0x04, "size", TC_ENDBLOCKDATA, TC_NULL,
+		size, TC_BLOCKDATA, 0x04, size, TC_NULL,
+	)
+}
@@ -0,0 +1,125 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "errors"
+import "strconv"
+import "strings"
+
+//sentinel errors wrapped by DecodeError, use errors.Is to tell them
+var (
+	ErrTruncated        = errors.New("Truncated stream")  //the stream ends in the middle of a content
+	ErrUnsupportedClass = errors.New("Unsupported class") //the class data can not be read, e.g. Externalizable of PROTOCOL_VERSION_1 without JavaExternalReader
+	ErrBadReference     = errors.New("Bad reference")     //TC_REFERENCE to a handle not assigned or of another type
+)
+
+//DecodeError where & why the decoding failed
+type DecodeError struct {
+	Offset   int64  //bytes read from the stream when it failed, -1 if unknown
+	Expected []byte //the type codes expected, nil if it is not a type code error
+	Actual   byte   //the type code read, only if Expected is not nil
+	Path     string //path through the object graph, e.g. root.orders[3].customer.name
+	Err      error  //the cause, it may wrap ErrTruncated, ErrUnsupportedClass or ErrBadReference
+}
+
+//Error implements error
+func (e *DecodeError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("Decode ")
+	sb.WriteString(e.Path)
+	if e.Offset >= 0 {
+		fmt.Fprintf(&sb, " at offset %d", e.Offset)
+	}
+	fmt.Fprintf(&sb, ": %v", e.Err)
+	return sb.String()
+}
+
+//Unwrap return the cause
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//typeCodeError the type code actual is none of expected, err describes it
+func typeCodeError(actual byte, expected []byte, err error) error {
+	return &DecodeError{
+		Offset:   -1,
+		Expected: expected,
+		Actual:   actual,
+		Err:      err,
+	}
+}
+
+//pathSegment one step of the path, field name or index of element
+type pathSegment struct {
+	name  string
+	index int
+}
+
+//pushField go into the field of object
+func (pool *JavaReferencePool) pushField(name string) {
+	ctx := pool.context()
+	ctx.path = append(ctx.path, pathSegment{name: name})
+}
+
+//pushIndex go into the elements of array, list or map, setIndex tells which one
+func (pool *JavaReferencePool) pushIndex() {
+	ctx := pool.context()
+	ctx.path = append(ctx.path, pathSegment{})
+}
+
+//setIndex the element being read
+func (pool *JavaReferencePool) setIndex(i int) {
+	ctx := pool.context()
+	ctx.path[len(ctx.path)-1].index = i
+}
+
+//popPath come out of the field or elements
+func (pool *JavaReferencePool) popPath() {
+	ctx := pool.context()
+	ctx.path = ctx.path[:len(ctx.path)-1]
+}
+
+//pathString e.g. root.orders[3].customer.name
+func (pool *JavaReferencePool) pathString() string {
+	var sb strings.Builder
+	sb.WriteString("root")
+	for _, seg := range pool.context().path {
+		if seg.name != "" {
+			sb.WriteString(".")
+			sb.WriteString(seg.name)
+		} else {
+			sb.WriteString("[")
+			sb.WriteString(strconv.Itoa(seg.index))
+			sb.WriteString("]")
+		}
+	}
+	return sb.String()
+}
+
+//decodeError convert err to *DecodeError with the current path & offset, the innermost path wins
+//*JavaException of TC_EXCEPTION is returned as it is
+func (pool *JavaReferencePool) decodeError(err error) error {
+	var de *DecodeError
+	var je *JavaException
+	if err == nil || errors.As(err, &je) {
+		return err
+	}
+	if errors.As(err, &de) && de.Path != "" {
+		return err
+	}
+	if de == nil {
+		de = &DecodeError{
+			Offset: -1,
+			Err:    err,
+		}
+		err = de
+	}
+	de.Path = pool.pathString()
+	if in := pool.context().in; in != nil {
+		de.Offset = in.Offset
+	}
+	if (errors.Is(de.Err, io.EOF) || errors.Is(de.Err, io.ErrUnexpectedEOF)) && !errors.Is(de.Err, ErrTruncated) {
+		de.Err = fmt.Errorf("%w: %w", ErrTruncated, de.Err)
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "errors"
+import "io"
+import "reflect"
+
+type errorCustomer struct {
+	_    struct{} `java:"com.example.Customer"`
+	Name string
+}
+
+type errorOrder struct {
+	_        struct{} `java:"com.example.Order"`
+	Customer *errorCustomer
+}
+
+type errorOrders struct {
+	_      struct{}      `java:"com.example.Orders"`
+	Orders []*errorOrder `java:"orders,list"`
+}
+
+func TestDecodeError(t *testing.T) {
+	orders := &errorOrders{}
+	for _, name := range []string{"c0", "c1", "c2", "c3"} {
+		orders.Orders = append(orders.Orders, &errorOrder{Customer: &errorCustomer{Name: name}})
+	}
+	data, err := Marshal(orders)
+	if err != nil {
+		t.Fatalf("Marshal got %v\n", err)
+	}
+	//TC_STRING of c3 -> 0x00
+	pos := bytes.Index(data, []byte("c3")) - 3
+	data[pos] = 0x00
+	var js JavaSerializer
+	err = Unmarshal(data, &js)
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("Expect *DecodeError, but got %v\n", err)
+	}
+	if de.Path != "root.orders[3].customer.name" || de.Offset != int64(pos+1) || de.Actual != 0x00 || len(de.Expected) == 0 {
+		t.Fatalf("Unexpected %#v %v\n", de, err)
+	}
+
+	//truncated
+	graph := javaStream(nodeGraph...)
+	err = Unmarshal(graph[:len(graph)-40], &js)
+	if !errors.Is(err, ErrTruncated) || !errors.As(err, &de) || de.Offset != int64(len(graph)-40) {
+		t.Fatalf("Expect ErrTruncated at the end, but got %v\n", err)
+	}
+
+	//n1.next refers to handle 0x7e0009
+	bad := javaStream(nodeGraph...)
+	pos = bytes.Index(bad, []byte{TC_REFERENCE, 0x00, 0x7E, 0x00, 0x03})
+	bad[pos+4] = 0x09
+	err = Unmarshal(bad, &js)
+	if !errors.Is(err, ErrBadReference) || !errors.As(err, &de) || de.Path != "root.next" {
+		t.Fatalf("Expect ErrBadReference at root.next, but got %v\n", err)
+	}
+
+	//class Base is not serializable, it has no classdata
+	base := javaStream(
+		TC_OBJECT, TC_CLASSDESC, 0x00, 0x04, "Base", make([]byte, 8), 0x00, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+	)
+	dec := NewDecoder(bytes.NewReader(base))
+	dec.SetStrict(true)
+	if err = dec.Decode(&js); !errors.Is(err, ErrUnsupportedClass) || !errors.As(err, &de) || de.Path != "root" {
+		t.Fatalf("Expect ErrUnsupportedClass at root, but got %v\n", err)
+	}
+	if !reflect.DeepEqual(de.Err, errors.Unwrap(err)) {
+		t.Fatalf("Expect Unwrap to the cause\n")
+	}
+
+	//stream header
+	if err = Unmarshal([]byte{0xAC, 0xED, 0x00, 0x06}, &js); !errors.As(err, &de) || de.Offset != 4 {
+		t.Fatalf("Expect *DecodeError of STREAM_VERSION, but got %v\n", err)
+	}
+	if err = Unmarshal([]byte{0xAC, 0xED, 0x00}, &js); !errors.Is(err, ErrTruncated) || !errors.As(err, &de) {
+		t.Fatalf("Expect ErrTruncated of the header, but got %v\n", err)
+	}
+	if err = Unmarshal(nil, &js); err != io.EOF {
+		t.Fatalf("Expect io.EOF of the empty stream, but got %v\n", err)
+	}
+}
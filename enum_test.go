@@ -0,0 +1,122 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+
+//javaStream concat the parts to a java serialization stream, string part is written as it is
+func javaStream(parts ...interface{}) []byte {
+	buff := new(bytes.Buffer)
+	buff.Write([]byte{0xAC, 0xED, 0x00, 0x05})
+	for _, p := range parts {
+		switch v := p.(type) {
+		case byte:
+			buff.WriteByte(v)
+		case int:
+			buff.WriteByte(byte(v))
+		case []byte:
+			buff.Write(v)
+		case string:
+			buff.WriteString(v)
+		}
+	}
+	return buff.Bytes()
+}
+
+//enumColorRed enum Color { RED } without the stream header
+var enumColorRed = []interface{}{
+	TC_ENUM,
+	TC_CLASSDESC, 0x00, 0x05, "Color", make([]byte, 8), SC_SERIALIZABLE | SC_ENUM, 0x00, 0x00, TC_ENDBLOCKDATA,
+	TC_CLASSDESC, 0x00, 0x0E, "java.lang.Enum", make([]byte, 8), SC_SERIALIZABLE | SC_ENUM, 0x00, 0x00, TC_ENDBLOCKDATA,
+	TC_NULL,
+	TC_STRING, 0x00, 0x03, "RED",
+}
+
+func TestEnumDeserialize(t *testing.T) {
+	data := javaStream(enumColorRed...)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	tcEnum, ok := v.(*JavaTcEnum)
+	if !ok {
+		t.Fatalf("Expect *JavaTcEnum, but got %v\n", v)
+	}
+	if tcEnum.JsonMap() != "RED" || len(tcEnum.Classes) != 2 || tcEnum.Classes[1].ClassName != "java.lang.Enum" {
+		t.Fatalf("Unexpected enum %v %v\n", tcEnum.JsonMap(), tcEnum.Classes)
+	}
+
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, tcEnum); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+	//builder should produce the same stream
+	out.Reset()
+	if err = SerializeJavaEntity(out, NewJavaTcEnum("Color", "RED")); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
+
+func TestEnumField(t *testing.T) {
+	//class Holder { Color color = Color.RED; Color other = Color.RED; }
+	parts := []interface{}{
+		TC_OBJECT,
+		TC_CLASSDESC, 0x00, 0x06, "Holder", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x02,
+		TC_OBJ_OBJECT, 0x00, 0x05, "color", TC_STRING, 0x00, 0x07, "LColor;",
+		TC_OBJ_OBJECT, 0x00, 0x05, "other", TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x01},
+		TC_ENDBLOCKDATA, TC_NULL,
+	}
+	parts = append(parts, enumColorRed...)
+	parts = append(parts, TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x05})
+	data := javaStream(parts...)
+
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	mp := v.JsonMap().(map[string]interface{})
+	if mp["color"] != "RED" || mp["other"] != "RED" {
+		t.Fatalf("Unexpected json map %v\n", mp)
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
+
+func TestEnumSameClass(t *testing.T) {
+	//Color[] { Color.RED, Color.GREEN }, the second TC_ENUM refers to the classDesc of Color
+	parts := []interface{}{
+		TC_ARRAY,
+		TC_CLASSDESC, 0x00, 0x08, "[LColor;", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x00, 0x00, 0x00, 0x02},
+	}
+	parts = append(parts, enumColorRed...)
+	parts = append(parts, TC_ENUM, TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x02}, TC_STRING, 0x00, 0x05, "GREEN")
+	data := javaStream(parts...)
+
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	tcArr := v.(*JavaTcArray)
+	red, green := tcArr.Values[0].(*JavaTcEnum), tcArr.Values[1].(*JavaTcEnum)
+	if red.ConstantName != "RED" || green.ConstantName != "GREEN" || green.Classes[0] != red.Classes[0] {
+		t.Fatalf("Unexpected enums %v %v\n", red, green)
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
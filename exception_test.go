@@ -0,0 +1,43 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "errors"
+
+func TestJavaException(t *testing.T) {
+	//new RuntimeException("outer", new IllegalStateException("inner")) written after TC_EXCEPTION
+	data := javaStream(
+		TC_OBJECT,
+		TC_CLASSDESC, 0x00, 0x06, "Holder", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x01,
+		TC_OBJ_OBJECT, 0x00, 0x01, "x", TC_STRING, 0x00, 0x12, "Ljava/lang/Object;",
+		TC_ENDBLOCKDATA, TC_NULL,
+		TC_EXCEPTION,
+		TC_OBJECT,
+		TC_CLASSDESC, 0x00, 0x1A, "java.lang.RuntimeException", []byte{0x9E, 0x5F, 0x06, 0x47, 0x0A, 0x34, 0x83, 0xE5}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA,
+		TC_CLASSDESC, 0x00, 0x13, "java.lang.Throwable", []byte{0xD5, 0xC6, 0x35, 0x27, 0x39, 0x77, 0xB8, 0xCB}, SC_RW_OBJECT, 0x00, 0x02,
+		TC_OBJ_OBJECT, 0x00, 0x05, "cause", TC_STRING, 0x00, 0x15, "Ljava/lang/Throwable;",
+		TC_OBJ_OBJECT, 0x00, 0x0D, "detailMessage", TC_STRING, 0x00, 0x12, "Ljava/lang/String;",
+		TC_ENDBLOCKDATA, TC_NULL,
+		//cause
+		TC_OBJECT,
+		TC_CLASSDESC, 0x00, 0x1F, "java.lang.IllegalStateException", []byte{0, 0, 0, 0, 0, 0, 0, 2}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA,
+		TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x01},
+		TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x06}, //cause of the cause is itself
+		TC_STRING, 0x00, 0x05, "inner",
+		TC_ENDBLOCKDATA,
+		TC_STRING, 0x00, 0x05, "outer",
+		TC_ENDBLOCKDATA,
+	)
+	_, err := DeserializeStream(bytes.NewReader(data))
+	var e *JavaException
+	if !errors.As(err, &e) {
+		t.Fatalf("Expect *JavaException, but got %v\n", err)
+	}
+	if e.ClassName != "java.lang.RuntimeException" || e.Message != "outer" || e.Offset != 52 {
+		t.Fatalf("Unexpected exception %+v\n", e)
+	}
+	if e.Cause == nil || e.Cause.ClassName != "java.lang.IllegalStateException" || e.Cause.Message != "inner" || e.Cause.Cause != nil {
+		t.Fatalf("Unexpected cause %+v\n", e.Cause)
+	}
+	t.Logf("Got error %v\n", err)
+}
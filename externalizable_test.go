@@ -0,0 +1,108 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "errors"
+import "io"
+import "reflect"
+
+//class Point implements Externalizable { writeExternal: writeInt(5), writeObject("p") }
+func externalPoint(scFlag byte, external ...interface{}) []byte {
+	parts := []interface{}{
+		TC_OBJECT,
+		TC_CLASSDESC, 0x00, 0x05, "Point", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, scFlag, 0x00, 0x00,
+		TC_ENDBLOCKDATA, TC_NULL,
+	}
+	return javaStream(append(parts, external...)...)
+}
+
+//extPoint value read by readExtPoint
+type extPoint struct {
+	X     int32
+	Label JavaSerializer
+}
+
+func (p *extPoint) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	return nil
+}
+
+func (p *extPoint) JsonMap() interface{} {
+	return map[string]interface{}{"x": p.X, "label": p.Label.JsonMap()}
+}
+
+func (p *extPoint) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	return nil
+}
+
+func readExtPoint(in *JavaObjectInput, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+	p := &extPoint{}
+	var err error
+	if p.X, err = in.ReadInt(); err != nil {
+		return nil, err
+	}
+	if p.Label, err = in.ReadObject(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func TestExternalizableGeneric(t *testing.T) {
+	data := externalPoint(SC_EXTERNALIZABLE|SC_BLOCK_DATA,
+		TC_BLOCKDATA, 0x04, []byte{0x00, 0x00, 0x00, 0x05}, TC_STRING, 0x00, 0x01, "p", TC_ENDBLOCKDATA)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	mp := v.JsonMap().(map[string]interface{})
+	expect := []interface{}{[]byte{0x00, 0x00, 0x00, 0x05}, JavaTcString("p")}
+	if !reflect.DeepEqual(mp["__external__"], expect) {
+		t.Fatalf("Expect %v, but got %v\n", expect, mp["__external__"])
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
+
+func TestExternalizableReader(t *testing.T) {
+	RegisterExternalReader("Point", readExtPoint)
+	defer RegisterExternalReader("Point", nil)
+	expect := map[string]interface{}{"x": int32(5), "label": JavaTcString("p")}
+
+	//protocol version 2, the data not read by readExternal is skipped
+	data := externalPoint(SC_EXTERNALIZABLE|SC_BLOCK_DATA,
+		TC_BLOCKDATA, 0x02, []byte{0x00, 0x00}, TC_BLOCKDATA, 0x02, []byte{0x00, 0x05},
+		TC_STRING, 0x00, 0x01, "p", TC_BLOCKDATA, 0x01, 0x09, TC_NULL, TC_ENDBLOCKDATA)
+	checkExtPoint(t, "v2", data, expect)
+
+	//protocol version 1, no block data
+	data = externalPoint(SC_EXTERNALIZABLE, []byte{0x00, 0x00, 0x00, 0x05}, TC_STRING, 0x00, 0x01, "p")
+	checkExtPoint(t, "v1", data, expect)
+
+	//protocol version 1 without reader, it is unknown where externalContents ends
+	RegisterExternalReader("Point", nil)
+	if _, err := DeserializeStream(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedClass) {
+		t.Fatalf("Expect ErrUnsupportedClass for protocol version 1 without reader, but got %v\n", err)
+	}
+}
+
+//checkExtPoint check the json data of Point in data, and it is written back as it is
+func checkExtPoint(t *testing.T, name string, data []byte, expect interface{}) {
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream %s got %v\n", name, err)
+	}
+	if mp := v.JsonMap().(map[string]interface{}); !reflect.DeepEqual(mp["__external__"], expect) {
+		t.Fatalf("%s expect %v, but got %v\n", name, expect, mp["__external__"])
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity %s got %v\n", name, err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("%s expect\n%x\nbut got\n%x\n", name, data, out.Bytes())
+	}
+}
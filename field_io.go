@@ -1,12 +1,19 @@
-package main
+package javaserializer
 
 import "fmt"
 import "io"
+import "math"
 
 //handle JavaFieldIO
 
-//ReadJavaField read java field
-func ReadJavaField(jf *JavaField, reader io.Reader, refs []*JavaReferenceObject) error {
+//ReadJavaField read java field, the error is *DecodeError with the path of the field
+func ReadJavaField(jf *JavaField, reader io.Reader, refs *JavaReferencePool) error {
+	refs.pushField(jf.FieldName)
+	defer refs.popPath()
+	return refs.decodeError(readJavaFieldValue(jf, reader, refs))
+}
+
+func readJavaFieldValue(jf *JavaField, reader io.Reader, refs *JavaReferencePool) error {
 	var err error
 	if IsPrimType(jf.FieldType) {
 		if jf.FieldValue, err = ReadTcPrimFieldValue(jf.FieldType, reader); err != nil {
@@ -43,8 +50,7 @@ func ReadTcPrimFieldValue(fType byte, reader io.Reader) (interface{}, error) {
 		if c, err := ReadUint16(reader); err != nil {
 			return nil, err
 		} else {
-			//StdLogger.Warn("Got TC_PRIM_CHAR VALUE %s\n", string(c))
-			return string(c), nil
+			return string(rune(c)), nil
 		}
 	case TC_PRIM_SHORT:
 		if s, err := ReadUint16(reader); err != nil {
@@ -68,13 +74,13 @@ func ReadTcPrimFieldValue(fType byte, reader io.Reader) (interface{}, error) {
 		if i, err := ReadUint32(reader); err != nil {
 			return nil, err
 		} else {
-			return float32(i), nil
+			return math.Float32frombits(i), nil
 		}
 	case TC_PRIM_DOUBLE:
 		if l, err := ReadUint64(reader); err != nil {
 			return nil, err
 		} else {
-			return float64(l), nil
+			return math.Float64frombits(l), nil
 		}
 	default:
 		return nil, fmt.Errorf("Unexpected prim_typecode 0x%x", fType)
@@ -82,36 +88,41 @@ func ReadTcPrimFieldValue(fType byte, reader io.Reader) (interface{}, error) {
 }
 
 //ReadTcObjFieldValue read tc object field value
-func ReadTcObjFieldValue(fType byte, fieldObjectClassName string, reader io.Reader, refs []*JavaReferenceObject) (interface{}, error) {
+func ReadTcObjFieldValue(fType byte, fieldObjectClassName string, reader io.Reader, refs *JavaReferencePool) (interface{}, error) {
 	if fType != TC_OBJ_OBJECT {
 		return nil, fmt.Errorf("Expected TC_OBJ_OBJECT, but got 0x%x", fType)
 	}
 	switch fieldObjectClassName {
 	case "Ljava/lang/String;":
-		return ReadNextTcString(reader, refs)
-	default:
-		//return nil, fmt.Errorf("Not support field value type classname [%s]", fieldObjectClassName)
-		//假设为TC_OBJECT
-		jo := &JavaTcObject{}
-		if err := jo.Deserialize(reader, refs); err != nil {
+		//TC_NULL 为nil, 以区分空字符串
+		if js, err := ReadNextEle(reader, refs); err != nil || js == nil {
 			return nil, err
+		} else if tcStr, ok := js.(*JavaTcString); !ok {
+			return nil, fmt.Errorf("Expected java.lang.String, but got %v", js)
 		} else {
-			return jo, nil
+			return string(*tcStr), nil
 		}
+	default:
+		//return nil, fmt.Errorf("Not support field value type classname [%s]", fieldObjectClassName)
+		//TC_OBJECT, TC_ENUM, TC_ARRAY, TC_REFERENCE or TC_NULL
+		return ReadNextEle(reader, refs)
 	}
 
 }
 
-//ReadTcArrayFieldValue read tc object field value
-func ReadTcArrayFieldValue(fType byte, fieldObjectClassName string, reader io.Reader, refs []*JavaReferenceObject) (interface{}, error) {
+//ReadTcArrayFieldValue read tc array field value
+//TC_REFERENCE 得到的是同一个 *JavaTcArray, TC_NULL 为nil
+func ReadTcArrayFieldValue(fType byte, fieldObjectClassName string, reader io.Reader, refs *JavaReferencePool) (interface{}, error) {
 	if fType != TC_OBJ_ARRAY {
 		return nil, fmt.Errorf("Expected TC_OBJ_ARRAY , but got 0x%x", fType)
 	}
-	tcArr := &JavaTcArray{}
-	if err := tcArr.Deserialize(reader, refs); err != nil {
+	if js, err := ReadNextEle(reader, refs); err != nil {
 		return nil, err
+	} else if js == nil {
+		return nil, nil
+	} else if tcArr, ok := js.(*JavaTcArray); !ok {
+		return nil, fmt.Errorf("Expected array %s, but got %v", fieldObjectClassName, js)
 	} else {
 		return tcArr, nil
 	}
-
 }
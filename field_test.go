@@ -1,4 +1,4 @@
-package main
+package javaserializer
 
 import "testing"
 import "sort"
@@ -28,6 +28,6 @@ func TestRune(t *testing.T) {
 	r := rune('你')
 	bs := make([]byte, 4)
 	utf8.EncodeRune(bs, r)
-	t.Logf("%s rune is %+q\n", r, r)
+	t.Logf("%c rune is %+q\n", r, r)
 	t.Logf("bs is %v\n", bs)
 }
@@ -0,0 +1,3 @@
+module github.com/davidwang2007/golang-java-serializer
+
+go 1.21
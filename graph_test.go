@@ -0,0 +1,70 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "reflect"
+
+//class Node implements Serializable { Node next; String name; }
+//n1.next = n1; n2.next = n1; n3.next = null
+var nodeGraph = []interface{}{
+	TC_OBJECT,
+	TC_CLASSDESC, 0x00, 0x04, "Node", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, SC_SERIALIZABLE, 0x00, 0x02,
+	TC_OBJ_OBJECT, 0x00, 0x04, "next", TC_STRING, 0x00, 0x06, "LNode;",
+	TC_OBJ_OBJECT, 0x00, 0x04, "name", TC_STRING, 0x00, 0x12, "Ljava/lang/String;",
+	TC_ENDBLOCKDATA, TC_NULL,
+	TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x03}, TC_STRING, 0x00, 0x01, "a",
+	TC_OBJECT, TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x00},
+	TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x03}, TC_STRING, 0x00, 0x01, "b",
+	TC_OBJECT, TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x00},
+	TC_NULL, TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x04},
+}
+
+func TestObjectGraph(t *testing.T) {
+	data := javaStream(nodeGraph...)
+	sr := NewJavaStreamReader(bytes.NewReader(data))
+	nodes := make([]*JavaTcObject, 3)
+	for i := range nodes {
+		content, err := sr.ReadContent()
+		if err != nil {
+			t.Fatalf("ReadContent [%d] got %v\n", i, err)
+		}
+		nodes[i] = content.Entity.(*JavaTcObject)
+	}
+	next := func(jo *JavaTcObject) interface{} {
+		return jo.Classes[0].Fields[0].FieldValue
+	}
+	if next(nodes[0]) != nodes[0] {
+		t.Fatalf("Expect n1.next is n1 itself, but got %v\n", next(nodes[0]))
+	}
+	if next(nodes[1]) != nodes[0] {
+		t.Fatalf("Expect n2.next is n1, but got %v\n", next(nodes[1]))
+	}
+	if next(nodes[2]) != nil {
+		t.Fatalf("Expect n3.next is null, but got %v\n", next(nodes[2]))
+	}
+	//同一个类的对象不共享field values
+	if name := nodes[0].Classes[0].Fields[1].FieldValue; name != "a" {
+		t.Fatalf("Expect n1.name a, but got %v\n", name)
+	}
+
+	ref := map[string]interface{}{JSON_REF_KEY: "$"}
+	if mp := nodes[0].JsonMap().(map[string]interface{}); !reflect.DeepEqual(mp["next"], ref) {
+		t.Fatalf("Expect %v, but got %v\n", ref, mp["next"])
+	}
+	mp := nodes[1].JsonMap().(map[string]interface{})
+	ref = map[string]interface{}{JSON_REF_KEY: "$.next"}
+	if n1, ok := mp["next"].(map[string]interface{}); !ok || n1["name"] != "a" || !reflect.DeepEqual(n1["next"], ref) {
+		t.Fatalf("Expect n2.next is n1 with %v, but got %v\n", ref, mp["next"])
+	}
+
+	out := new(bytes.Buffer)
+	sw := NewJavaStreamWriter(out)
+	for _, jo := range nodes {
+		if err := sw.WriteEntity(jo); err != nil {
+			t.Fatalf("WriteEntity got %v\n", err)
+		}
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
@@ -0,0 +1,382 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "math"
+import "encoding/binary"
+
+// blockdata:
+// 	blockdatashort: TC_BLOCKDATA (unsigned byte)<size> (byte)[size]
+// 	blockdatalong: TC_BLOCKDATALONG (int)<size> (byte)[size]
+//
+// ObjectOutputStream.writeInt/writeUTF等直接写入的基本类型数据, 以及writeObject方法中写的数据,
+// 都以block data的形式出现, java每写满1024字节分一个block, 一个int可能被拆在二个block中
+
+const MAX_BLOCK_SIZE = 1024 //java ObjectOutputStream 的block大小
+
+//ReadBlockDataContent read the data of one block, TC_BLOCKDATA or TC_BLOCKDATALONG has been consumed already
+func ReadBlockDataContent(tc byte, reader io.Reader) ([]byte, error) {
+	var size int
+	switch tc {
+	case TC_BLOCKDATA:
+		if l, err := ReadNextByte(reader); err != nil {
+			return nil, err
+		} else {
+			size = int(l)
+		}
+	case TC_BLOCKDATALONG:
+		if l, err := ReadUint32(reader); err != nil {
+			return nil, err
+		} else if l > math.MaxInt32 {
+			return nil, fmt.Errorf("TC_BLOCKDATALONG size %d is too large", l)
+		} else {
+			size = int(l)
+		}
+	default:
+		return nil, typeCodeError(tc, []byte{TC_BLOCKDATA, TC_BLOCKDATALONG}, fmt.Errorf("Expect TC_BLOCKDATA, but got 0x%x", tc))
+	}
+	return ReadNextBytes(reader, size)
+}
+
+//WriteBlockDataContent write data as exactly one block, TC_BLOCKDATALONG is used for more than 255 bytes
+func WriteBlockDataContent(writer io.Writer, data []byte) error {
+	header := make([]byte, 5)
+	var n int
+	if len(data) <= 0xFF {
+		header[0] = TC_BLOCKDATA
+		header[1] = byte(len(data))
+		n = 2
+	} else {
+		header[0] = TC_BLOCKDATALONG
+		binary.BigEndian.PutUint32(header[1:5], uint32(len(data)))
+		n = 5
+	}
+	if _, err := writer.Write(header[:n]); err != nil {
+		return err
+	}
+	_, err := writer.Write(data)
+	return err
+}
+
+//JavaBlockDataReader DataInput like reader over consecutive block data, aka ObjectInputStream.readInt etc.
+//read across the block boundary transparently, io.EOF is returned when the next content is not block data
+type JavaBlockDataReader struct {
+	reader  io.Reader
+	remain  int                //unread bytes in current block
+	refs    *JavaReferencePool //only for top level block data, TC_RESET between the blocks will reset it
+	raw     bool               //protocol version 1 externalizable data, no block framing at all
+	strict  bool               //ReadUTF rejects the malformed modified UTF-8, see Decoder.SetStrict
+	peek    byte               //type code after the last block when reader is not io.ByteScanner
+	hasPeek bool
+	//recording: keep the raw contents read in recorded, block data as []byte, see JavaExternalizable
+	recording bool
+	recorded  []interface{}
+	newBlock  bool //the data read next starts a new block
+}
+
+//NewJavaBlockDataReader new block data reader, reader should be positioned at TC_BLOCKDATA or TC_BLOCKDATALONG
+//if reader implements io.ByteScanner, the type code after the last block is unread for the following content,
+//otherwise it is kept by the block data reader, see JavaObjectInput
+func NewJavaBlockDataReader(reader io.Reader) *JavaBlockDataReader {
+	return &JavaBlockDataReader{
+		reader: reader,
+	}
+}
+
+//Remain return unread bytes in current block
+func (br *JavaBlockDataReader) Remain() int {
+	return br.remain
+}
+
+//nextBlock read next block header
+func (br *JavaBlockDataReader) nextBlock() error {
+	var b byte
+	var err error
+	if br.hasPeek {
+		b, br.hasPeek = br.peek, false
+	} else if br.refs != nil {
+		b, err = ReadNextContentTypeCode(br.reader, br.refs)
+	} else {
+		b, err = ReadNextByte(br.reader)
+	}
+	if err != nil {
+		return err
+	}
+	switch b {
+	case TC_BLOCKDATA:
+		if l, err := ReadNextByte(br.reader); err != nil {
+			return err
+		} else {
+			br.remain = int(l)
+		}
+	case TC_BLOCKDATALONG:
+		if l, err := ReadUint32(br.reader); err != nil {
+			return err
+		} else if l > math.MaxInt32 {
+			return fmt.Errorf("TC_BLOCKDATALONG size %d is too large", l)
+		} else {
+			br.remain = int(l)
+		}
+	default:
+		//不是block data, 退回给后续的content
+		if bs, ok := br.reader.(io.ByteScanner); !ok {
+			br.peek, br.hasPeek = b, true
+		} else if err = bs.UnreadByte(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+	return nil
+}
+
+//Read implements io.Reader, read the data of the blocks
+func (br *JavaBlockDataReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if br.raw {
+		n, err := br.reader.Read(p)
+		br.record(p[:n])
+		return n, err
+	}
+	for br.remain == 0 {
+		if err := br.nextBlock(); err != nil {
+			return 0, err
+		}
+		br.newBlock = true
+	}
+	if len(p) > br.remain {
+		p = p[:br.remain]
+	}
+	n, err := br.reader.Read(p)
+	br.remain -= n
+	br.record(p[:n])
+	return n, err
+}
+
+//record keep the data read if recording, the data of the same block is kept in one []byte
+func (br *JavaBlockDataReader) record(p []byte) {
+	if !br.recording || len(p) == 0 {
+		return
+	}
+	if last := len(br.recorded) - 1; last >= 0 && !br.newBlock {
+		if bs, ok := br.recorded[last].([]byte); ok {
+			br.recorded[last] = append(bs, p...)
+			return
+		}
+	}
+	br.newBlock = false
+	br.recorded = append(br.recorded, append([]byte(nil), p...))
+}
+
+//ReadFully read len(p) bytes, aka DataInput.readFully
+func (br *JavaBlockDataReader) ReadFully(p []byte) error {
+	if _, err := io.ReadFull(br, p); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("Try to read %d bytes from block data, but reach the end: %w", len(p), err)
+		}
+		return err
+	}
+	return nil
+}
+
+//readN read n bytes, n should be no more than 8
+func (br *JavaBlockDataReader) readN(n int) ([]byte, error) {
+	bs := make([]byte, n)
+	if err := br.ReadFully(bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+//ReadBoolean aka DataInput.readBoolean
+func (br *JavaBlockDataReader) ReadBoolean() (bool, error) {
+	b, err := br.ReadByte()
+	return b != 0, err
+}
+
+//ReadByte aka DataInput.readByte, it implements io.ByteReader
+func (br *JavaBlockDataReader) ReadByte() (byte, error) {
+	if bs, err := br.readN(1); err != nil {
+		return 0, err
+	} else {
+		return bs[0], nil
+	}
+}
+
+//ReadShort aka DataInput.readShort
+func (br *JavaBlockDataReader) ReadShort() (int16, error) {
+	if bs, err := br.readN(2); err != nil {
+		return 0, err
+	} else {
+		return int16(binary.BigEndian.Uint16(bs)), nil
+	}
+}
+
+//ReadUnsignedShort aka DataInput.readUnsignedShort
+func (br *JavaBlockDataReader) ReadUnsignedShort() (uint16, error) {
+	s, err := br.ReadShort()
+	return uint16(s), err
+}
+
+//ReadChar aka DataInput.readChar, return UTF-16 unit
+func (br *JavaBlockDataReader) ReadChar() (rune, error) {
+	s, err := br.ReadShort()
+	return rune(uint16(s)), err
+}
+
+//ReadInt aka DataInput.readInt
+func (br *JavaBlockDataReader) ReadInt() (int32, error) {
+	if bs, err := br.readN(4); err != nil {
+		return 0, err
+	} else {
+		return int32(binary.BigEndian.Uint32(bs)), nil
+	}
+}
+
+//ReadLong aka DataInput.readLong
+func (br *JavaBlockDataReader) ReadLong() (int64, error) {
+	if bs, err := br.readN(8); err != nil {
+		return 0, err
+	} else {
+		return int64(binary.BigEndian.Uint64(bs)), nil
+	}
+}
+
+//ReadFloat aka DataInput.readFloat
+func (br *JavaBlockDataReader) ReadFloat() (float32, error) {
+	i, err := br.ReadInt()
+	return math.Float32frombits(uint32(i)), err
+}
+
+//ReadDouble aka DataInput.readDouble
+func (br *JavaBlockDataReader) ReadDouble() (float64, error) {
+	l, err := br.ReadLong()
+	return math.Float64frombits(uint64(l)), err
+}
+
+//ReadUTF aka DataInput.readUTF, 2 bytes length + modified UTF-8
+func (br *JavaBlockDataReader) ReadUTF() (string, error) {
+	l, err := br.ReadUnsignedShort()
+	if err != nil {
+		return "", err
+	}
+	bs := make([]byte, int(l))
+	if err = br.ReadFully(bs); err != nil {
+		return "", err
+	}
+	return DecodeModifiedUTF8(bs, br.strict || br.refs != nil && br.refs.strict())
+}
+
+//JavaBlockDataWriter DataOutput like writer, buffer the data and write it as block data
+//aka ObjectOutputStream.writeInt etc., Flush must be called before writing any object
+type JavaBlockDataWriter struct {
+	writer io.Writer
+	buff   []byte
+}
+
+//NewJavaBlockDataWriter new block data writer
+func NewJavaBlockDataWriter(writer io.Writer) *JavaBlockDataWriter {
+	return &JavaBlockDataWriter{
+		writer: writer,
+		buff:   make([]byte, 0, MAX_BLOCK_SIZE),
+	}
+}
+
+//Flush write buffered data as one block
+func (bw *JavaBlockDataWriter) Flush() error {
+	if len(bw.buff) == 0 {
+		return nil
+	}
+	if err := WriteBlockDataContent(bw.writer, bw.buff); err != nil {
+		return err
+	}
+	bw.buff = bw.buff[:0]
+	return nil
+}
+
+//Write implements io.Writer, the data is split into blocks of MAX_BLOCK_SIZE like java does
+func (bw *JavaBlockDataWriter) Write(p []byte) (int, error) {
+	n := 0
+	for len(p) > 0 {
+		c := MAX_BLOCK_SIZE - len(bw.buff)
+		if c > len(p) {
+			c = len(p)
+		}
+		bw.buff = append(bw.buff, p[:c]...)
+		p = p[c:]
+		n += c
+		if len(bw.buff) == MAX_BLOCK_SIZE {
+			if err := bw.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+//WriteBoolean aka DataOutput.writeBoolean
+func (bw *JavaBlockDataWriter) WriteBoolean(v bool) error {
+	if v {
+		return bw.WriteByte(1)
+	}
+	return bw.WriteByte(0)
+}
+
+//WriteByte aka DataOutput.writeByte, it implements io.ByteWriter
+func (bw *JavaBlockDataWriter) WriteByte(v byte) error {
+	_, err := bw.Write([]byte{v})
+	return err
+}
+
+//WriteShort aka DataOutput.writeShort
+func (bw *JavaBlockDataWriter) WriteShort(v int16) error {
+	bs := make([]byte, 2)
+	binary.BigEndian.PutUint16(bs, uint16(v))
+	_, err := bw.Write(bs)
+	return err
+}
+
+//WriteChar aka DataOutput.writeChar, v should be UTF-16 unit
+func (bw *JavaBlockDataWriter) WriteChar(v rune) error {
+	return bw.WriteShort(int16(uint16(v)))
+}
+
+//WriteInt aka DataOutput.writeInt
+func (bw *JavaBlockDataWriter) WriteInt(v int32) error {
+	bs := make([]byte, 4)
+	binary.BigEndian.PutUint32(bs, uint32(v))
+	_, err := bw.Write(bs)
+	return err
+}
+
+//WriteLong aka DataOutput.writeLong
+func (bw *JavaBlockDataWriter) WriteLong(v int64) error {
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, uint64(v))
+	_, err := bw.Write(bs)
+	return err
+}
+
+//WriteFloat aka DataOutput.writeFloat
+func (bw *JavaBlockDataWriter) WriteFloat(v float32) error {
+	return bw.WriteInt(int32(math.Float32bits(v)))
+}
+
+//WriteDouble aka DataOutput.writeDouble
+func (bw *JavaBlockDataWriter) WriteDouble(v float64) error {
+	return bw.WriteLong(int64(math.Float64bits(v)))
+}
+
+//WriteUTF aka DataOutput.writeUTF, 2 bytes length + modified UTF-8
+func (bw *JavaBlockDataWriter) WriteUTF(v string) error {
+	bs := EncodeModifiedUTF8(v)
+	if len(bs) > math.MaxUint16 {
+		return fmt.Errorf("WriteUTF encoded string too long: %d bytes", len(bs))
+	}
+	if err := bw.WriteShort(int16(uint16(len(bs)))); err != nil {
+		return err
+	}
+	_, err := bw.Write(bs)
+	return err
+}
@@ -0,0 +1,97 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "encoding/binary"
+
+// newClass:
+// 	TC_CLASS classDesc newHandle
+//
+// 例如 String.class 序列化后为:
+// 	TC_CLASS TC_CLASSDESC "java.lang.String" serialVersionUID SC_SERIALIZABLE 0 TC_ENDBLOCKDATA TC_NULL newHandle
+// 基本类型如 int.class 的classDesc SC_FLAG为0x00, serialVersionUID为0L
+
+//Deserialize deserialize stream to tc class
+func (tcClass *JavaTcClass) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcClass] >> ++BEGIN\n")
+	defer refs.logger().Debug("[JavaTcClass] << --END\n")
+	var b byte
+	var err error
+	if b, err = ReadNextByte(reader); err != nil {
+		return err
+	}
+	if TC_REFERENCE == b { //表示引用了另一个TC_CLASS
+		if refIndex, err := ReadUint32(reader); err != nil {
+			return err
+		} else {
+			if ref, err := refs.Get(refIndex); err != nil {
+				return err
+			} else if ref.RefType != TC_CLASS {
+				return fmt.Errorf("%w: [JavaTcClass] Expect ref [0x%x] type TC_CLASS, but 0x%x", ErrBadReference, refIndex, ref.RefType)
+			} else if cp, ok := ref.Val.(*JavaTcClass); !ok {
+				return fmt.Errorf("%w: [JavaTcClass] Expect ref [0x%x] val *JavaTcClass, but %v", ErrBadReference, refIndex, ref.Val)
+			} else {
+				tcClass.ClassDesc = cp.ClassDesc
+				return nil
+			}
+		}
+	} else if TC_CLASS == b { //证明开头的tc_class未被消费，则再读下一个
+		if b, err = ReadNextByte(reader); err != nil {
+			return err
+		}
+	}
+	return tcClass.deserializeBody(b, reader, refs)
+}
+
+//deserializeBody read classDesc and newHandle, TC_CLASS has been consumed already
+//b is the first byte of classDesc, TC_CLASSDESC, TC_PROXYCLASSDESC or TC_REFERENCE to classDesc
+func (tcClass *JavaTcClass) deserializeBody(b byte, reader io.Reader, refs *JavaReferencePool) error {
+	if classes, err := ReadClassDescChain(b, reader, refs); err != nil {
+		return err
+	} else if len(classes) == 0 {
+		return fmt.Errorf("[JavaTcClass] Expected TC_CLASSDESC, but got TC_NULL")
+	} else {
+		tcClass.ClassDesc = classes[0]
+	}
+	//newHandle
+	AddReference(refs, TC_CLASS, tcClass)
+	refs.logger().Debug("[JavaTcClass] class %s\n", tcClass.ClassDesc.ClassName)
+	return nil
+}
+
+//JsonMap class is represented by its class name
+func (tcClass *JavaTcClass) JsonMap() interface{} {
+	return tcClass.ClassDesc.ClassName
+}
+
+//Serialize serialize JavaTcClass to stream
+func (tcClass *JavaTcClass) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcClass] Serialize >> \n")
+	defer refs.logger().Debug("[JavaTcClass] Serialize << \n")
+
+	buff := make([]byte, 5)
+	var err error
+	//there is only one java.lang.Class instance for each class
+	if handle, ok := refs.Lookup(tcClass); ok {
+		buff[0] = TC_REFERENCE
+		binary.BigEndian.PutUint32(buff[1:5], handle)
+		refs.logger().Debug("[JavaTcClass] Serialize got Reference 0x%x \n", handle)
+		_, err = writer.Write(buff[:5])
+		return err
+	}
+
+	buff[0] = TC_CLASS
+	if _, err = writer.Write(buff[:1]); err != nil {
+		return err
+	}
+	if err = SerializeClassDescChain(writer, refs, tcClass.ClassDesc.Chain()); err != nil {
+		return err
+	}
+	//add reference
+	AddReference(refs, TC_CLASS, tcClass)
+	return nil
+}
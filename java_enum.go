@@ -0,0 +1,109 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "encoding/binary"
+
+// newEnum:
+// 	TC_ENUM classDesc newHandle enumConstantName
+// enumConstantName:
+// 	(String)object
+//
+// enum的classDesc链通常为 枚举类 -> java.lang.Enum, 二者SC_FLAG均为 SC_SERIALIZABLE|SC_ENUM (0x12),
+// serialVersionUID均为0L, 且没有field
+
+//JavaTcEnum represent java enum constant
+type JavaTcEnum struct {
+	Classes      []*JavaTcClassDesc //enum class desc chain, the last one is java.lang.Enum
+	ConstantName string             //enum constant name, aka Enum.name()
+}
+
+//Deserialize deserialize stream to tc enum
+func (tcEnum *JavaTcEnum) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcEnum] >> ++BEGIN\n")
+	defer refs.logger().Debug("[JavaTcEnum] << --END\n")
+	var b byte
+	var err error
+	if b, err = ReadNextByte(reader); err != nil {
+		return err
+	}
+	if TC_REFERENCE == b { //表示引用了另一个TC_ENUM
+		if refIndex, err := ReadUint32(reader); err != nil {
+			return err
+		} else {
+			if ref, err := refs.Get(refIndex); err != nil {
+				return err
+			} else if ref.RefType != TC_ENUM {
+				return fmt.Errorf("%w: [JavaTcEnum] Expect ref [0x%x] type TC_ENUM, but 0x%x", ErrBadReference, refIndex, ref.RefType)
+			} else if ep, ok := ref.Val.(*JavaTcEnum); !ok {
+				return fmt.Errorf("%w: [JavaTcEnum] Expect ref [0x%x] val *JavaTcEnum, but %v", ErrBadReference, refIndex, ref.Val)
+			} else {
+				tcEnum.Classes = ep.Classes
+				tcEnum.ConstantName = ep.ConstantName
+				return nil
+			}
+		}
+	} else if TC_ENUM == b { //证明开头的tc_enum未被消费，则再读下一个
+		if b, err = ReadNextByte(reader); err != nil {
+			return err
+		}
+	}
+	return tcEnum.deserializeBody(b, reader, refs)
+}
+
+//deserializeBody read classDesc, newHandle and enumConstantName, TC_ENUM has been consumed already
+//b is the first byte of classDesc, TC_CLASSDESC or TC_REFERENCE to classDesc
+func (tcEnum *JavaTcEnum) deserializeBody(b byte, reader io.Reader, refs *JavaReferencePool) error {
+	var err error
+	if tcEnum.Classes, err = ReadClassDescChain(b, reader, refs); err != nil {
+		return err
+	} else if len(tcEnum.Classes) == 0 {
+		return fmt.Errorf("[JavaTcEnum] Expected TC_CLASSDESC, but got TC_NULL")
+	}
+	//newHandle
+	AddReference(refs, TC_ENUM, tcEnum)
+
+	if tcEnum.ConstantName, err = ReadNextTcString(reader, refs); err != nil {
+		return err
+	}
+	refs.logger().Debug("[JavaTcEnum] %s.%s\n", tcEnum.Classes[0].ClassName, tcEnum.ConstantName)
+	return nil
+}
+
+//JsonMap enum is represented by its constant name
+func (tcEnum *JavaTcEnum) JsonMap() interface{} {
+	return tcEnum.ConstantName
+}
+
+//Serialize serialize JavaTcEnum to stream
+func (tcEnum *JavaTcEnum) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcEnum] Serialize >> \n")
+	defer refs.logger().Debug("[JavaTcEnum] Serialize << \n")
+
+	buff := make([]byte, 5)
+	var err error
+	//enum constant is singleton, the same class & name always refer to the same handle
+	if handle, ok := refs.Lookup(tcEnum); ok {
+		buff[0] = TC_REFERENCE
+		binary.BigEndian.PutUint32(buff[1:5], handle)
+		refs.logger().Debug("[JavaTcEnum] Serialize got Reference 0x%x \n", handle)
+		_, err = writer.Write(buff[:5])
+		return err
+	}
+
+	buff[0] = TC_ENUM
+	if _, err = writer.Write(buff[:1]); err != nil {
+		return err
+	}
+	if err = SerializeClassDescChain(writer, refs, tcEnum.Classes); err != nil {
+		return err
+	}
+	//add reference
+	AddReference(refs, TC_ENUM, tcEnum)
+
+	return NewJavaTcString(tcEnum.ConstantName).Serialize(writer, refs)
+}
@@ -0,0 +1,114 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "strings"
+
+// exception:
+// 	TC_EXCEPTION reset (Throwable)object reset
+//
+// java写入过程中出错时(例如NotSerializableException), ObjectOutputStream会清空handle表,
+// 写入TC_EXCEPTION及该Throwable对象, 再清空一次handle表
+// ObjectInputStream读到后会抛出WriteAbortedException, 此处返回 *JavaException
+
+//JavaException the Throwable written by TC_EXCEPTION, it implements error
+type JavaException struct {
+	ClassName string         //class name of the throwable
+	Message   string         //Throwable.detailMessage
+	Cause     *JavaException //Throwable.cause, nil if there is no cause
+	Offset    int64          //offset of TC_EXCEPTION in the stream, -1 if unknown
+	Throwable *JavaTcObject  //the throwable object itself, nil for the causes
+}
+
+//Error implements error
+func (e *JavaException) Error() string {
+	var sb strings.Builder
+	sb.WriteString("java stream aborted")
+	if e.Offset >= 0 {
+		fmt.Fprintf(&sb, " at offset %d", e.Offset)
+	}
+	sb.WriteString(" by ")
+	for c := e; c != nil; c = c.Cause {
+		if c != e {
+			sb.WriteString("; caused by: ")
+		}
+		sb.WriteString(c.ClassName)
+		if c.Message != "" {
+			sb.WriteString(": ")
+			sb.WriteString(c.Message)
+		}
+	}
+	return sb.String()
+}
+
+//Unwrap return the cause, so errors.As can walk the cause chain
+func (e *JavaException) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+	return e.Cause
+}
+
+//ReadJavaException read the Throwable after TC_EXCEPTION, TC_EXCEPTION has been consumed already
+//it always return a non-nil error, *JavaException if the throwable is read successfully
+func ReadJavaException(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaException] >>\n")
+	defer refs.logger().Debug("[JavaException] <<\n")
+	offset := StreamOffset(reader)
+	if offset > 0 {
+		offset -= 1 //TC_EXCEPTION itself
+	}
+	ResetReference(refs)
+	if b, err := ReadNextByte(reader); err != nil {
+		return err
+	} else if b != TC_OBJECT {
+		return typeCodeError(b, []byte{TC_OBJECT}, fmt.Errorf("Expect TC_OBJECT after TC_EXCEPTION at offset %d, but got 0x%x", offset, b))
+	}
+	jo := &JavaTcObject{}
+	if b, err := ReadNextByte(reader); err != nil {
+		return err
+	} else if err = jo.deserializeBody(b, reader, refs); err != nil {
+		return fmt.Errorf("Read the throwable after TC_EXCEPTION at offset %d failed: %w", offset, err)
+	}
+	ResetReference(refs)
+
+	e := NewJavaException(jo.JsonMap())
+	if e == nil {
+		e = &JavaException{}
+	}
+	e.ClassName = jo.Classes[0].ClassName
+	e.Offset = offset
+	e.Throwable = jo
+	refs.logger().Debug("[JavaException] %v\n", e)
+	return e
+}
+
+//NewJavaException build JavaException from the json data of a throwable
+func NewJavaException(jsonData interface{}) *JavaException {
+	mp, ok := jsonData.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	//cause 指向自己时表示没有cause, 此时其json数据为环的标记
+	if _, isRef := IsJsonRef(mp); isRef {
+		return nil
+	}
+	e := &JavaException{
+		Offset: -1,
+	}
+	//最后一个__class__为最终的子类
+	for i := 0; ; i++ {
+		if name, ok := mp[fmt.Sprintf("__class__%d", i)].(string); ok {
+			e.ClassName = name
+		} else {
+			break
+		}
+	}
+	if msg, ok := mp["detailMessage"].(string); ok {
+		e.Message = msg
+	}
+	e.Cause = NewJavaException(mp["cause"])
+	return e
+}
@@ -0,0 +1,207 @@
+package javaserializer
+
+import "io"
+import "fmt"
+
+// classdata for SC_EXTERNALIZABLE:
+// 	externalContents	// SC_BLOCK_DATA 为0, PROTOCOL_VERSION_1, 只有该类的readExternal才能读出
+// 	objectAnnotation	// SC_BLOCK_DATA 为1, PROTOCOL_VERSION_2, block data及对象, 以TC_ENDBLOCKDATA结束
+//
+// Externalizable 对象只有最终子类的writeExternal写入数据, 父类没有各自的classdata
+// JDK 1.2 以后默认使用 PROTOCOL_VERSION_2, 例如 java.time.Ser, 很多缓存库的对象
+// 无论是否注册JavaExternalReader, 原始的block data及对象都按原样保存在 JavaTcClassDesc.RwDatas 中, Serialize 将其写回
+
+//JavaExternalReader read the data written by writeExternal of one class, aka readExternal
+//the returned JavaSerializer is kept in JavaExternalizable.Value for JsonMap & Unmarshal, it is not used by Serialize
+type JavaExternalReader func(in *JavaObjectInput, classDesc *JavaTcClassDesc) (JavaSerializer, error)
+
+//RegisterExternalReader register the reader of Externalizable class to DefaultTypeRegistry, nil to remove it
+//it is required for the streams written by PROTOCOL_VERSION_1
+func RegisterExternalReader(className string, reader JavaExternalReader) {
+	DefaultTypeRegistry.RegisterExternalReader(className, reader)
+}
+
+//LookupExternalReader return the reader of the class registered to DefaultTypeRegistry, nil if not registered
+func LookupExternalReader(className string) JavaExternalReader {
+	return DefaultTypeRegistry.LookupExternalReader(className)
+}
+
+//JavaObjectInput aka ObjectInput passed to readExternal
+//primitive data is read from block data, objects are read by ReadObject
+type JavaObjectInput struct {
+	*JavaBlockDataReader
+	refs *JavaReferencePool
+}
+
+//nextTypeCode read the type code of next object
+func (in *JavaObjectInput) nextTypeCode() (byte, error) {
+	if in.remain > 0 {
+		return 0, fmt.Errorf("[JavaObjectInput] %d bytes of block data left before the object", in.remain)
+	}
+	if in.hasPeek {
+		in.hasPeek = false
+		return in.peek, nil
+	}
+	return ReadNextByte(in.reader)
+}
+
+//ReadObject aka ObjectInput.readObject, nil for TC_NULL
+func (in *JavaObjectInput) ReadObject() (JavaSerializer, error) {
+	b, err := in.nextTypeCode()
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case TC_NULL:
+		in.recordObject(nil)
+		return nil, nil
+	case TC_ENDBLOCKDATA:
+		if !in.raw {
+			//留给skipCustomData
+			in.peek, in.hasPeek = b, true
+			return nil, fmt.Errorf("[JavaObjectInput] No more object before TC_ENDBLOCKDATA")
+		}
+	case TC_BLOCKDATA, TC_BLOCKDATALONG:
+		return nil, fmt.Errorf("[JavaObjectInput] Expect object, but got block data 0x%x", b)
+	}
+	js, err := ReadEleWithTypeCode(b, in.reader, in.refs)
+	if err != nil {
+		return nil, err
+	}
+	in.recordObject(js)
+	return js, nil
+}
+
+//recordObject keep the object read if recording, nil for TC_NULL
+func (in *JavaObjectInput) recordObject(js JavaSerializer) {
+	if in.recording {
+		if js == nil {
+			in.recorded = append(in.recorded, nil)
+		} else {
+			in.recorded = append(in.recorded, js)
+		}
+	}
+}
+
+//skipCustomData skip the data which is not read by readExternal until TC_ENDBLOCKDATA, it is still recorded
+func (in *JavaObjectInput) skipCustomData() error {
+	if in.remain > 0 {
+		if err := in.ReadFully(make([]byte, in.remain)); err != nil {
+			return err
+		}
+	}
+	for {
+		b, err := in.nextTypeCode()
+		if err != nil {
+			return err
+		}
+		switch b {
+		case TC_ENDBLOCKDATA:
+			return nil
+		case TC_NULL:
+			in.recordObject(nil)
+		case TC_BLOCKDATA, TC_BLOCKDATALONG:
+			if data, err := ReadBlockDataContent(b, in.reader); err != nil {
+				return err
+			} else if in.recording {
+				in.recorded = append(in.recorded, data)
+			}
+		default:
+			if js, err := ReadEleWithTypeCode(b, in.reader, in.refs); err != nil {
+				return err
+			} else {
+				in.recordObject(js)
+			}
+		}
+	}
+}
+
+//JavaExternalizable classdata of the Externalizable object
+//the raw contents are kept in ClassDesc.RwDatas, block data as []byte and the objects, Serialize writes them back
+type JavaExternalizable struct {
+	ClassDesc *JavaTcClassDesc //the most derived class
+	Value     JavaSerializer   //read by the registered JavaExternalReader, nil if not registered
+}
+
+//Deserialize 从classdata部分开始读取
+func (ext *JavaExternalizable) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	className := ext.ClassDesc.ClassName
+	refs.logger().Debug("[JavaExternalizable] %s >>\n", className)
+	defer refs.logger().Debug("[JavaExternalizable] %s <<\n", className)
+
+	blockData := ext.ClassDesc.ScFlag&SC_BLOCK_DATA != 0
+	fn := refs.registry().LookupExternalReader(className)
+	if fn == nil {
+		if !blockData {
+			//不知道externalContents在哪里结束, 无法跳过
+			return fmt.Errorf("%w: [JavaExternalizable] %s is written by PROTOCOL_VERSION_1, it cannot be read without a registered JavaExternalReader", ErrUnsupportedClass, className)
+		}
+		var err error
+		ext.ClassDesc.RwDatas, err = ReadObjectAnnotation(reader, refs)
+		return err
+	}
+
+	//PROTOCOL_VERSION_1 没有block data, 由readExternal尽力读取
+	in := &JavaObjectInput{
+		JavaBlockDataReader: NewJavaBlockDataReader(reader),
+		refs:                refs,
+	}
+	in.raw = !blockData
+	in.strict = refs.strict()
+	in.recording = true
+	in.recorded = make([]interface{}, 0)
+	var err error
+	if ext.Value, err = fn(in, ext.ClassDesc); err != nil {
+		return fmt.Errorf("[JavaExternalizable] readExternal of %s failed: %w", className, err)
+	}
+	if blockData {
+		if err = in.skipCustomData(); err != nil {
+			return err
+		}
+	}
+	ext.ClassDesc.RwDatas = in.recorded
+	return nil
+}
+
+//JsonMap return the json data of Value, or the raw contents if there is no JavaExternalReader
+func (ext *JavaExternalizable) JsonMap() interface{} {
+	return newJsonVisitor().value(ext, "$")
+}
+
+func (ext *JavaExternalizable) jsonMap(v *jsonVisitor, path string) interface{} {
+	if ext.Value != nil {
+		return v.value(ext.Value, path)
+	}
+	return v.values(ext.ClassDesc.RwDatas, path)
+}
+
+//Serialize write ClassDesc.RwDatas back
+func (ext *JavaExternalizable) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaExternalizable] Serialize %s >>\n", ext.ClassDesc.ClassName)
+	defer refs.logger().Debug("[JavaExternalizable] Serialize %s <<\n", ext.ClassDesc.ClassName)
+	if ext.ClassDesc.ScFlag&SC_BLOCK_DATA != 0 {
+		return WriteObjectAnnotation(writer, refs, ext.ClassDesc.RwDatas)
+	}
+	//PROTOCOL_VERSION_1, 没有block data的框架
+	for i, item := range ext.ClassDesc.RwDatas {
+		var err error
+		switch v := item.(type) {
+		case nil:
+			_, err = writer.Write([]byte{TC_NULL})
+		case []byte:
+			_, err = writer.Write(v)
+		case JavaSerializer:
+			err = v.Serialize(writer, refs)
+		default:
+			err = fmt.Errorf("[JavaExternalizable] Unsupport external content [%d] %v", i, item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
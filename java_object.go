@@ -1,14 +1,12 @@
-package main
+package javaserializer
 
 import "fmt"
 import "math"
-import "bytes"
 import "reflect"
 import "io"
 import "sort"
 import "strings"
 import "encoding/binary"
-import "encoding/json"
 
 // https://courses.cs.washington.edu/courses/cse341/98au/java/jdk1.2beta4/docs/guide/serialization/spec/protocol.doc5.html
 //
@@ -67,8 +65,12 @@ type JavaTcClassDesc struct {
 	ScFlag           byte   //Sc flag, indicate serializable mechanism, current support TC_RW_OBJECT, SC_SERIALIZABLE
 	SerialVersionUID uint64 // serialVersionUID
 	//newHandle
-	Fields  []*JavaField  //it's fields
-	RwDatas []interface{} //for SC_RW_OBJECT CUSTOM WRITER
+	Fields     []*JavaField     //it's fields
+	RwDatas    []interface{}    //for SC_RW_OBJECT CUSTOM WRITER
+	SuperClass *JavaTcClassDesc //super class desc, nil means TC_NULL
+	//TC_PROXYCLASSDESC, dynamic proxy class has no name & serialVersionUID, only the interfaces
+	IsProxy         bool
+	ProxyInterfaces []string
 }
 
 //SortFields sort fields by name to generate class desc fields description
@@ -84,10 +86,29 @@ func (classDesc *JavaTcClassDesc) AddField(jf *JavaField) {
 	classDesc.Fields = append(classDesc.Fields, jf)
 }
 
-//JavaTcClass represent java tc_class
+//Chain return classDesc itself and all of its super classDesc, 子类在前
+func (classDesc *JavaTcClassDesc) Chain() []*JavaTcClassDesc {
+	classes := make([]*JavaTcClassDesc, 0, 4)
+	for cd := classDesc; cd != nil; cd = cd.SuperClass {
+		classes = append(classes, cd)
+	}
+	return classes
+}
+
+//IsExternalizable judge if the class implements java.io.Externalizable
+func (classDesc *JavaTcClassDesc) IsExternalizable() bool {
+	return classDesc.ScFlag&SC_EXTERNALIZABLE != 0
+}
+
+//HasWriteMethod judge if the Serializable class has its own writeObject method
+func (classDesc *JavaTcClassDesc) HasWriteMethod() bool {
+	return classDesc.ScFlag&SC_SERIALIZABLE != 0 && classDesc.ScFlag&SC_WRITE_METHOD != 0
+}
+
+//JavaTcClass represent java tc_class, aka java.lang.Class value
 //it is rarely used
 type JavaTcClass struct {
-	ClassDesc JavaTcClassDesc //class desc
+	ClassDesc *JavaTcClassDesc //class desc, super classes are linked by SuperClass
 	//newHandle
 }
 
@@ -96,60 +117,61 @@ type JavaTcArray struct {
 	ClassDesc *JavaTcClassDesc //class desc
 	//newHandle
 	SerialVersionUID uint64        // serialVersionUID
-	Values           []interface{} //values [size]
-	JsonData         []interface{}
+	Values           []interface{} //values [size], the elements of object array
+	Prims            interface{}   //typed slice of primitive array, e.g. []byte for [B, []int32 for [I, see readPrimArray
 }
 
 //JavaTcObject  represent java tc object
 type JavaTcObject struct {
 	Classes          []*JavaTcClassDesc //it's classes, including the parent class
 	SerialVersionUID uint64             // serialVersionUID
-	JsonData         interface{}        //即协议中的classdata[]; map, slice, 8大基本类型或包装类型以及String
+	rwObjects        []JavaSerializer   //classdata read by custom readers, 与Classes一一对应, 反序列化时才有
 }
 
 type JavaTcString string
 
 // Deserialize implements JavaSerializer
-func (tcStr *JavaTcString) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcString] Deserialize >> \n")
-	defer StdLogger.Debug("[JavaTcString] Deserialize << \n")
+func (tcStr *JavaTcString) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcString] Deserialize >> \n")
+	defer refs.logger().Debug("[JavaTcString] Deserialize << \n")
 	buff := make([]byte, 4)
-	if _, err := reader.Read(buff[:1]); err != nil {
+	if _, err := io.ReadFull(reader, buff[:1]); err != nil {
 		return err
 	}
-	var strLen uint16
-	var err error
 	switch buff[0] {
 	case TC_REFERENCE:
 		if refIndex, err := ReadUint32(reader); err != nil {
 			return err
 		} else {
-			ref := refs[refIndex-INTBASE_WIRE_HANDLE]
-			if ref.RefType != TC_STRING {
-				return fmt.Errorf("[JavaTcString] Expect [%d] RefType TC_STRING, but 0x%x", refIndex-INTBASE_WIRE_HANDLE, ref.RefType)
+			if ref, err := refs.Get(refIndex); err != nil {
+				return err
+			} else if ref.RefType != TC_STRING {
+				return fmt.Errorf("%w: [JavaTcString] Expect [0x%x] RefType TC_STRING, but 0x%x", ErrBadReference, refIndex, ref.RefType)
 			} else {
 				if str, ok := ref.Val.(string); !ok {
-					return fmt.Errorf("[JavaTcString] ref [%d] should be string, but %v", refIndex-INTBASE_WIRE_HANDLE, ref.Val)
+					return fmt.Errorf("%w: [JavaTcString] ref [0x%x] should be string, but %v", ErrBadReference, refIndex, ref.Val)
 				} else {
 					*tcStr = JavaTcString(str)
 				}
 				return nil
 			}
 		}
-	case TC_STRING:
-		if strLen, err = ReadUint16(reader); err != nil {
+	case TC_STRING, TC_LONGSTRING:
+		if str, err := ReadTcStringContent(buff[0], reader, refs); err != nil {
 			return err
+		} else {
+			*tcStr = JavaTcString(str)
+			return nil
 		}
-		fallthrough
-	default: //假设头一个字节已消耗
-		if _, err := reader.Read(buff[1:2]); err != nil {
+	default: //假设头一个字节TC_STRING已消耗
+		if _, err := io.ReadFull(reader, buff[1:2]); err != nil {
 			return err
 		}
-		strLen = binary.BigEndian.Uint16(buff[:2])
+		strLen := binary.BigEndian.Uint16(buff[:2])
 
-		if str, err := ReadUTFString(reader, int(strLen)); err != nil {
+		if str, err := readUTFString(reader, int(strLen), refs.strict()); err != nil {
 			return err
 		} else {
 			*tcStr = JavaTcString(str)
@@ -161,45 +183,40 @@ func (tcStr *JavaTcString) Deserialize(reader io.Reader, refs []*JavaReferenceOb
 }
 
 //Serialize JavaTcString to stream
-func (tcStr *JavaTcString) Serialize(write io.Writer, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcString] Serialize >> \n")
-	defer StdLogger.Debug("[JavaTcString] Serialize << \n")
-	var refIndex int = -1
-	for i := 0; i < len(refs); i++ {
-		ref := refs[i]
-		if ref == nil {
-			break
-		}
-		if ref.RefType != TC_STRING {
-			continue
-		}
-		if str, ok := ref.Val.(string); ok && string(*tcStr) == str {
-			refIndex = i
-			break
-		} else if tsp, ok := ref.Val.(*JavaTcString); ok && *tsp == *tcStr {
-			refIndex = i
-			break
-		}
-	}
+func (tcStr *JavaTcString) Serialize(write io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcString] Serialize >> \n")
+	defer refs.logger().Debug("[JavaTcString] Serialize << \n")
+	refIndex, found := refs.Lookup(tcStr)
 	var err error
-	buff := make([]byte, 5)
-	if refIndex >= 0 {
+	buff := make([]byte, 9)
+	if found {
 		buff[0] = TC_REFERENCE
-		refIndex += INTBASE_WIRE_HANDLE
-		binary.BigEndian.PutUint32(buff[1:5], uint32(refIndex))
+		binary.BigEndian.PutUint32(buff[1:5], refIndex)
 		_, err = write.Write(buff[:5])
 		return err
 	}
 
 	//write tc_string, len,
-	strBs := ([]byte)(*tcStr)
-	buff[0] = TC_STRING
-	binary.BigEndian.PutUint16(buff[1:3], uint16(len(strBs)))
-	if _, err = write.Write(buff[:3]); err != nil {
+	//超过65535字节的用TC_LONGSTRING, 长度为8字节
+	strBs, err := encodeUTFString(string(*tcStr), refs.strict())
+	if err != nil {
 		return err
 	}
+	if len(strBs) > math.MaxUint16 {
+		buff[0] = TC_LONGSTRING
+		binary.BigEndian.PutUint64(buff[1:9], uint64(len(strBs)))
+		if _, err = write.Write(buff[:9]); err != nil {
+			return err
+		}
+	} else {
+		buff[0] = TC_STRING
+		binary.BigEndian.PutUint16(buff[1:3], uint16(len(strBs)))
+		if _, err = write.Write(buff[:3]); err != nil {
+			return err
+		}
+	}
 	if _, err = write.Write(strBs); err != nil {
 		return err
 	}
@@ -219,38 +236,42 @@ func (tcStr *JavaTcString) JsonMap() interface{} {
 //Deserialize stream to JavaTcClassDesc
 //一个classDesc从TC_CLASSDESC开始，以TC_ENDBLOCKDATA终
 //一个TC_OBJECT包含多个TC_CLASSDESC
-func (classDesc *JavaTcClassDesc) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcClassDesc] >> ++ BEGIN\n")
-	defer StdLogger.Debug("[JavaTcClassDesc] << --END\n")
+func (classDesc *JavaTcClassDesc) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcClassDesc] >> ++ BEGIN\n")
+	defer refs.logger().Debug("[JavaTcClassDesc] << --END\n")
 	//TC_CLASSDESC
 	var buff = make([]byte, 4)
 	var err error
 	var classNameLen uint16
-	if _, err = reader.Read(buff[:2]); err != nil {
+	if _, err = io.ReadFull(reader, buff[:2]); err != nil {
 		return err
 	}
 	if TC_CLASSDESC == buff[0] { //证明这个开头的指示TC_CLASSDESC未被消费掉
-		if _, err = reader.Read(buff[2:3]); err != nil {
+		if _, err = io.ReadFull(reader, buff[2:3]); err != nil {
 			return err
 		}
 		classNameLen = binary.BigEndian.Uint16(buff[1:3])
 	} else if TC_REFERENCE == buff[0] { //表示引用了另一个CLASSDESC
-		//读剩下的3个字节，后二个字节表示refIndex
-		if _, err = reader.Read(buff[:3]); err != nil {
+		//读剩下的3个字节，与已读的1个字节共同表示handle
+		buff = append(buff, 0)
+		if _, err = io.ReadFull(reader, buff[2:5]); err != nil {
 			return err
 		} else {
-			refIndex := binary.BigEndian.Uint16(buff[1:3])
-			ref := refs[int(refIndex)]
-			if ref.RefType != TC_CLASSDESC {
-				return fmt.Errorf("[JavaTcClassDesc] Expect ref [%d] type TC_CLASSDESC, but 0x%x", refIndex, ref.RefType)
+			refIndex := binary.BigEndian.Uint32(buff[1:5])
+			if ref, err := refs.Get(refIndex); err != nil {
+				return err
+			} else if ref.RefType != TC_CLASSDESC {
+				return fmt.Errorf("%w: [JavaTcClassDesc] Expect ref [0x%x] type TC_CLASSDESC, but 0x%x", ErrBadReference, refIndex, ref.RefType)
 			} else if cdp, ok := ref.Val.(*JavaTcClassDesc); !ok {
-				return fmt.Errorf("[JavaTcClassDesc] Expect ref[%d] val *JavaTcClassDesc, but %v", refIndex, ref.Val)
+				return fmt.Errorf("%w: [JavaTcClassDesc] Expect ref [0x%x] val *JavaTcClassDesc, but %v", ErrBadReference, refIndex, ref.Val)
 			} else {
 				classDesc.ClassName = cdp.ClassName
 				classDesc.SerialVersionUID = cdp.SerialVersionUID
+				classDesc.ScFlag = cdp.ScFlag
 				classDesc.Fields = cdp.Fields
+				classDesc.SuperClass = cdp.SuperClass
 				return nil
 			}
 		}
@@ -258,11 +279,11 @@ func (classDesc *JavaTcClassDesc) Deserialize(reader io.Reader, refs []*JavaRefe
 		//标志已被消费掉
 		classNameLen = binary.BigEndian.Uint16(buff[:2])
 	}
-	StdLogger.Debug("[JavaTcClassDesc] TRY TO Read classDesc.className, len=%d\n", classNameLen)
-	if classDesc.ClassName, err = ReadUTFString(reader, int(classNameLen)); err != nil {
+	refs.logger().Debug("[JavaTcClassDesc] TRY TO Read classDesc.className, len=%d\n", classNameLen)
+	if classDesc.ClassName, err = readUTFString(reader, int(classNameLen), refs.strict()); err != nil {
 		return err
 	}
-	StdLogger.Debug("[JavaTcClassDesc] classDesc.className is [%s]\n", classDesc.ClassName)
+	refs.logger().Debug("[JavaTcClassDesc] classDesc.className is [%s]\n", classDesc.ClassName)
 	if classDesc.SerialVersionUID, err = ReadUint64(reader); err != nil {
 		return err
 	}
@@ -271,11 +292,12 @@ func (classDesc *JavaTcClassDesc) Deserialize(reader io.Reader, refs []*JavaRefe
 
 	//next byte
 	//various flag, This particular flag says that the object supports serialization.
-	//current time just support 0x02 SC_SERIALIZABLE & some SC_RW_OBJECT
+	//保留原始的flag, 例如 0x02 SC_SERIALIZABLE, 0x03 SC_RW_OBJECT, 0x0C SC_EXTERNALIZABLE|SC_BLOCK_DATA, 0x12 enum
+	//0x00 表示不可序列化的类, 只会出现在TC_CLASS中, 例如 int.class, Object.class
 	if sc, err := ReadNextByte(reader); err != nil {
 		return err
-	} else if sc != SC_SERIALIZABLE && sc != SC_RW_OBJECT {
-		return fmt.Errorf("[JavaTcClassDesc] Cannot handle Serializable flag 0x%x", sc)
+	} else if sc&SC_SERIALIZABLE != 0 && sc&SC_EXTERNALIZABLE != 0 {
+		return fmt.Errorf("[JavaTcClassDesc] %s serializable and externalizable flags conflict: 0x%x", classDesc.ClassName, sc)
 	} else {
 		classDesc.ScFlag = sc
 	}
@@ -283,7 +305,7 @@ func (classDesc *JavaTcClassDesc) Deserialize(reader io.Reader, refs []*JavaRefe
 	if numberOfFields, err := ReadUint16(reader); err != nil {
 		return err
 	} else {
-		StdLogger.Debug("[JavaTcClassDesc] %s has %d fields\n", classDesc.ClassName, numberOfFields)
+		refs.logger().Debug("[JavaTcClassDesc] %s has %d fields\n", classDesc.ClassName, numberOfFields)
 		classDesc.Fields = make([]*JavaField, int(numberOfFields))
 		for i := 0; i < int(numberOfFields); i++ {
 			if classDesc.Fields[i], err = ReadNextJavaField(reader, refs); err != nil {
@@ -297,47 +319,39 @@ func (classDesc *JavaTcClassDesc) Deserialize(reader io.Reader, refs []*JavaRefe
 	if b, err := ReadNextByte(reader); err != nil {
 		return err
 	} else if b != TC_ENDBLOCKDATA {
-		return fmt.Errorf("[JavaTcClassDesc] Expect TC_ENDBLOCKDATA 0x78, but got 0x%x", b)
+		return typeCodeError(b, []byte{TC_ENDBLOCKDATA}, fmt.Errorf("[JavaTcClassDesc] Expect TC_ENDBLOCKDATA 0x78, but got 0x%x", b))
 	}
 	return nil
 }
 
 //Serialize serialize JavaTcClassDesc to stream
 //2018-02-02 11:15:09
-func (classDesc *JavaTcClassDesc) Serialize(writer io.Writer, refs []*JavaReferenceObject) error {
+func (classDesc *JavaTcClassDesc) Serialize(writer io.Writer, refs *JavaReferencePool) error {
 	//judge if exists in ref
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcClassDesc] Serialize >> \n")
-	defer StdLogger.Debug("[JavaTcClassDesc] Serialize << \n")
-	var refIndex int = -1
-	for i := 0; i < len(refs); i++ {
-		ref := refs[i]
-		if ref == nil {
-			break
-		}
-		if ref.RefType != TC_CLASSDESC {
-			continue
-		}
-		if tcdp, ok := ref.Val.(*JavaTcClassDesc); ok && tcdp.SerialVersionUID == classDesc.SerialVersionUID {
-			refIndex = i
-			break
-		}
-	}
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcClassDesc] Serialize >> \n")
+	defer refs.logger().Debug("[JavaTcClassDesc] Serialize << \n")
+	refIndex, found := FindClassDescReference(refs, classDesc)
 	var err error
 	buff := make([]byte, 8)
-	if refIndex >= 0 {
+	if found {
 		buff[0] = TC_REFERENCE
-		refIndex += INTBASE_WIRE_HANDLE
-		binary.BigEndian.PutUint32(buff[1:5], uint32(refIndex))
+		binary.BigEndian.PutUint32(buff[1:5], refIndex)
 		_, err = writer.Write(buff[:5])
 		return err
 	}
+	if classDesc.IsProxy {
+		return classDesc.serializeProxy(writer, refs)
+	}
 	//ordinary serialize
 	//0x72
 	buff[0] = TC_CLASSDESC
 	//classname length uint16
-	classNameArr := ([]byte)(classDesc.ClassName)
+	classNameArr, err := encodeUTFString(classDesc.ClassName, refs.strict())
+	if err != nil {
+		return err
+	}
 	binary.BigEndian.PutUint16(buff[1:3], uint16(len(classNameArr)))
 	if _, err = writer.Write(buff[:3]); err != nil { // TC_CLASSDESC & classNameLen sum 3 bytes
 		return err
@@ -370,13 +384,19 @@ func (classDesc *JavaTcClassDesc) Serialize(writer io.Writer, refs []*JavaRefere
 	//writer all fields type declaration
 	//classDesc.SortFields()
 	for i, jf := range classDesc.Fields {
-		StdLogger.Debug("[JavaTcClassDesc] Serialize field [%d] %v \n", i, jf)
+		refs.logger().Debug("[JavaTcClassDesc] Serialize field [%d] %v \n", i, jf)
 		//1byte type + 2 byte len + n byte fieldName
 		buff[0] = jf.FieldType
-		fieldNameArr := ([]byte)(jf.FieldName)
+		fieldNameArr, err := encodeUTFString(jf.FieldName, refs.strict())
+		if err != nil {
+			return err
+		}
 		binary.BigEndian.PutUint16(buff[1:3], uint16(len(fieldNameArr)))
 		fObjNameArr := ([]byte)(jf.FieldObjectClassName)
 		var modifiedName string = jf.FieldObjectClassName
+		if len(fObjNameArr) == 0 && (jf.FieldType == TC_OBJ_ARRAY || jf.FieldType == TC_OBJ_OBJECT) {
+			return fmt.Errorf("[JavaTcClassDesc] Empty class name of field %s", jf.FieldName)
+		}
 		if _, err = writer.Write(buff[:3]); err != nil {
 			return err
 		}
@@ -398,7 +418,7 @@ func (classDesc *JavaTcClassDesc) Serialize(writer io.Writer, refs []*JavaRefere
 					//替换.为/ 最后加;
 					modifiedName = fmt.Sprintf("[L%s;", strings.Replace(jf.FieldObjectClassName, ".", "/", -1))
 				}
-				StdLogger.Debug("[JavaTcClassDesc] Serialize modify field name %s » %s \n", jf.FieldObjectClassName, modifiedName)
+				refs.logger().Debug("[JavaTcClassDesc] Serialize modify field name %s » %s \n", jf.FieldObjectClassName, modifiedName)
 			}
 			//write it out
 			tcString := new(JavaTcString)
@@ -414,7 +434,7 @@ func (classDesc *JavaTcClassDesc) Serialize(writer io.Writer, refs []*JavaRefere
 				//要prefix上 L
 				//替换.为/ 最后加;
 				modifiedName = fmt.Sprintf("L%s;", strings.Replace(jf.FieldObjectClassName, ".", "/", -1))
-				StdLogger.Debug("[JavaTcClassDesc] Serialize modify field name %s » %s \n", jf.FieldObjectClassName, modifiedName)
+				refs.logger().Debug("[JavaTcClassDesc] Serialize modify field name %s » %s \n", jf.FieldObjectClassName, modifiedName)
 			}
 			//write it out
 			tcString := new(JavaTcString)
@@ -434,158 +454,315 @@ func (classDesc *JavaTcClassDesc) Serialize(writer io.Writer, refs []*JavaRefere
 	return nil
 }
 
+//FindClassDescReference find the handle of classDesc already written, false if not found
+//enum class desc's serialVersionUID are all 0L, so className must be matched too
+func FindClassDescReference(refs *JavaReferencePool, classDesc *JavaTcClassDesc) (uint32, bool) {
+	return refs.Lookup(classDesc)
+}
+
+//ReadClassDescChain read classDesc and all of its super classDesc until TC_NULL
+//b is the first typecode which has been consumed already, TC_CLASSDESC, TC_REFERENCE or TC_NULL
+//返回的顺序为子类在前，父类在后
+func ReadClassDescChain(b byte, reader io.Reader, refs *JavaReferencePool) ([]*JavaTcClassDesc, error) {
+	classes := make([]*JavaTcClassDesc, 0, 4)
+	var err error
+	for {
+		switch b {
+		case TC_NULL:
+			return classes, nil
+		case TC_REFERENCE:
+			//引用了之前的classDesc, 其父类也已确定, 不会再有TC_NULL结束符
+			refIndex, err := ReadUint32(reader)
+			if err != nil {
+				return nil, err
+			}
+			ref, err := refs.Get(refIndex)
+			if err != nil {
+				return nil, err
+			}
+			tcd, ok := ref.Val.(*JavaTcClassDesc)
+			if ref.RefType != TC_CLASSDESC || !ok {
+				return nil, fmt.Errorf("%w: Expected TC_CLASSDESC @ ref [0x%x], but got %v", ErrBadReference, refIndex, ref.Val)
+			}
+			if len(classes) > 0 {
+				classes[len(classes)-1].SuperClass = tcd
+			}
+			return append(classes, tcd.Chain()...), nil
+		case TC_CLASSDESC:
+			tcs := &JavaTcClassDesc{}
+			refs.logger().Debug("[ReadClassDescChain] try to get classDesc [%d]\n", len(classes))
+			if err = tcs.Deserialize(reader, refs); err != nil {
+				return nil, err
+			}
+			if len(classes) > 0 {
+				classes[len(classes)-1].SuperClass = tcs
+			}
+			classes = append(classes, tcs)
+		case TC_PROXYCLASSDESC:
+			tcs := &JavaTcClassDesc{}
+			refs.logger().Debug("[ReadClassDescChain] try to get proxy classDesc [%d]\n", len(classes))
+			if err = tcs.DeserializeProxy(reader, refs); err != nil {
+				return nil, err
+			}
+			if len(classes) > 0 {
+				classes[len(classes)-1].SuperClass = tcs
+			}
+			classes = append(classes, tcs)
+		default:
+			return nil, typeCodeError(b, []byte{TC_CLASSDESC, TC_PROXYCLASSDESC, TC_REFERENCE, TC_NULL}, fmt.Errorf("[ReadClassDescChain] Expected TC_CLASSDESC, but got 0x%x", b))
+		}
+		if b, err = ReadNextByte(reader); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//SerializeClassDescChain write classDesc and its super classDesc, end with TC_NULL
+//once a classDesc is written as TC_REFERENCE, its super classes are implied
+func SerializeClassDescChain(writer io.Writer, refs *JavaReferencePool, classes []*JavaTcClassDesc) error {
+	var err error
+	for _, cs := range classes {
+		if _, found := FindClassDescReference(refs, cs); found {
+			return cs.Serialize(writer, refs)
+		}
+		if err = cs.Serialize(writer, refs); err != nil {
+			return err
+		}
+	}
+	_, err = writer.Write([]byte{TC_NULL})
+	return err
+}
+
+//ReadTcObject read next TC_OBJECT, TC_REFERENCE or TC_NULL from the stream, nil for TC_NULL
+//every TC_REFERENCE to one handle returns the same *JavaTcObject, even if the object is still being read
+func ReadTcObject(reader io.Reader, refs *JavaReferencePool) (*JavaTcObject, error) {
+	js, err := readNextEleOf(TC_OBJECT, reader, refs)
+	if err != nil || js == nil {
+		return nil, err
+	} else if jo, ok := js.(*JavaTcObject); ok {
+		return jo, nil
+	}
+	return nil, fmt.Errorf("%w: [JavaTcObject] Expect *JavaTcObject, but got %T", ErrBadReference, js)
+}
+
+//readNextEleOf read next element of type code tc, TC_REFERENCE or TC_NULL
+func readNextEleOf(tc byte, reader io.Reader, refs *JavaReferencePool) (JavaSerializer, error) {
+	b, err := ReadNextByte(reader)
+	if err != nil {
+		return nil, err
+	}
+	switch b {
+	case tc, TC_REFERENCE, TC_NULL:
+		return ReadEleWithTypeCode(b, reader, refs)
+	}
+	return nil, typeCodeError(b, []byte{tc, TC_REFERENCE, TC_NULL}, fmt.Errorf("Expect 0x%x, TC_REFERENCE or TC_NULL, but got 0x%x", tc, b))
+}
+
 //Deserialize deserialize stream to tc object
-func (jo *JavaTcObject) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcObject] >> ++BEGIN\n")
-	defer StdLogger.Debug("[JavaTcObject] << --END\n")
+//if the stream is TC_REFERENCE to an object read before, the classes & classdata of that object are copied into jo,
+//so jo is a distinct object of the same java identity
+//
+//Deprecated: use ReadTcObject, which returns the shared *JavaTcObject for TC_REFERENCE.
+func (jo *JavaTcObject) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcObject] >> ++BEGIN\n")
+	defer refs.logger().Debug("[JavaTcObject] << --END\n")
 	//firstly, analysis all tc_classdesc
 	//TC_OBJECT
 	var buff = make([]byte, 4)
 	var err error
-	if _, err = reader.Read(buff[:1]); err != nil {
+	if _, err = io.ReadFull(reader, buff[:1]); err != nil {
 		return err
 	}
 	if TC_REFERENCE == buff[0] { //表示引用了另一个TC_OBJECT
-		//读剩下的3个字节，后二个字节表示refIndex
-		if _, err = reader.Read(buff[:4]); err != nil {
+		//读剩下的4个字节，表示handle
+		if refIndex, err := ReadUint32(reader); err != nil {
 			return err
 		} else {
-			refIndex := binary.BigEndian.Uint16(buff[2:4])
-			ref := refs[int(refIndex)]
-			if ref.RefType != TC_OBJECT {
-				return fmt.Errorf("[JavaTcObject] Expect ref [%d] type TC_OBJECT, but 0x%x", refIndex, ref.RefType)
+			if ref, err := refs.Get(refIndex); err != nil {
+				return err
+			} else if ref.RefType != TC_OBJECT {
+				return fmt.Errorf("%w: [JavaTcObject] Expect ref [0x%x] type TC_OBJECT, but 0x%x", ErrBadReference, refIndex, ref.RefType)
 			} else if jop, ok := ref.Val.(*JavaTcObject); !ok {
-				return fmt.Errorf("[JavaTcObject] Expect ref[%d] val *JavaTcObject, but %v", refIndex, ref.Val)
+				return fmt.Errorf("%w: [JavaTcObject] Expect ref [0x%x] val *JavaTcObject, but %v", ErrBadReference, refIndex, ref.Val)
 			} else {
 				jo.Classes = jop.Classes
-				jo.JsonData = jop.JsonData
+				jo.SerialVersionUID = jop.SerialVersionUID
+				jo.rwObjects = jop.rwObjects
 				return nil
 			}
 		}
 	} else if TC_OBJECT == buff[0] { //证明开头的tc_object未被消费，则再读下一个
-		if _, err = reader.Read(buff[:1]); err != nil {
+		if _, err = io.ReadFull(reader, buff[:1]); err != nil {
 			return err
 		}
 	}
+	return jo.deserializeBody(buff[0], reader, refs)
+}
 
+//deserializeBody read classDesc, newHandle and classdata, TC_OBJECT has been consumed already
+//b is the first byte of classDesc, TC_CLASSDESC, TC_PROXYCLASSDESC or TC_REFERENCE to classDesc
+func (jo *JavaTcObject) deserializeBody(b byte, reader io.Reader, refs *JavaReferencePool) error {
+	var err error
+	if err = refs.enter(); err != nil {
+		return err
+	}
+	defer refs.leave()
 	//now begin tc_classdesc
-	jo.Classes = make([]*JavaTcClassDesc, 0, 1<<7)
-
-out:
-	for {
-		switch buff[0] {
-		case TC_REFERENCE:
-			//在TC_OBJECT 之后遇到 TC_REFERENCE后，就不会有0x78,0x70结束符了
-			if _, err = reader.Read(buff[:4]); err != nil {
-				StdLogger.Debug("[JavaTcObject] try to get classDesc ref failed: %v\n", err)
-			} else {
-				refIndex := int(binary.BigEndian.Uint32(buff[:4]))
-				ref := refs[refIndex-INTBASE_WIRE_HANDLE]
-				if ref.RefType != TC_CLASSDESC {
-					return fmt.Errorf("Expected TC_CLASSDESC @ ref [%d]", refIndex-INTBASE_WIRE_HANDLE)
-				} else if tcd, ok := ref.Val.(*JavaTcClassDesc); ok {
-					jo.Classes = append(jo.Classes, tcd)
-					break out
-				} else {
-					return fmt.Errorf("Unexpected error when deserialize TC_OBJECT, read TC_CLASSDESC, ref=%v", ref)
-				}
-
-			}
-		case TC_CLASSDESC:
-			tcs := &JavaTcClassDesc{}
-			StdLogger.Debug("[JavaTcObject] try to get classDesc [%d]\n", len(jo.Classes))
-			jo.Classes = append(jo.Classes, tcs)
-			if err := tcs.Deserialize(reader, refs); err != nil {
-				return err
-			}
-		case TC_NULL:
-			//newHandle
-			AddReference(refs, TC_OBJECT, jo)
-			break out
-		default:
-			return fmt.Errorf("[JavaTcObject] Expected TC_CLASSDESC, but got 0x%x", buff[0])
+	//在TC_OBJECT 之后遇到 TC_REFERENCE后，就不会有0x78,0x70结束符了
+	if jo.Classes, err = ReadClassDescChain(b, reader, refs); err != nil {
+		return err
+	} else if len(jo.Classes) == 0 {
+		return fmt.Errorf("[JavaTcObject] Expected TC_CLASSDESC, but got TC_NULL")
+	}
+	//每个对象有自己的field values, classDesc本身仍然共享
+	jo.Classes = cloneClassDescChain(jo.Classes)
+	jo.SerialVersionUID = jo.Classes[0].SerialVersionUID
+	//newHandle, 之后classdata中对该对象的TC_REFERENCE得到的就是jo本身
+	AddReference(refs, TC_OBJECT, jo)
+	jo.rwObjects = make([]JavaSerializer, len(jo.Classes))
+	//Externalizable 只有最终子类的externalContents
+	if jo.Classes[0].IsExternalizable() {
+		ext := &JavaExternalizable{
+			ClassDesc: jo.Classes[0],
 		}
-		if _, err := reader.Read(buff[:1]); err != nil {
+		if err = ext.Deserialize(reader, refs); err != nil {
 			return err
 		}
+		jo.rwObjects[0] = ext
+		return nil
 	}
 	//iterate the classes
 	for i := len(jo.Classes) - 1; i >= 0; i -= 1 {
 		//由于序列化时先序列化父类的Field, 所以要先从父类的Field反序列化
 		cc := jo.Classes[i]
-		if cc.ScFlag == SC_RW_OBJECT {
-			if sub, err := DeserializeScRwObject(reader, refs, cc.ClassName); err != nil {
+		if cc.HasWriteMethod() {
+			if sub, err := DeserializeScRwObject(reader, refs, cc); err != nil {
 				return err
 			} else {
-				cc.RwDatas = []interface{}{sub}
+				jo.rwObjects[i] = sub
 			}
-		} else if cc.ScFlag == SC_SERIALIZABLE {
+		} else if cc.ScFlag&SC_SERIALIZABLE != 0 {
 			for _, jf := range cc.Fields {
 				if err = ReadJavaField(jf, reader, refs); err != nil {
 					return err
 				}
 			}
+		} else if refs.strict() {
+			return fmt.Errorf("%w: [JavaTcObject] Unexpected SC_FLAG [0x%x] for class [%s]", ErrUnsupportedClass, cc.ScFlag, cc.ClassName)
 		} else {
-			StdLogger.Error("[JavaTcObject] Unexpected SC_FLAG [0x%x] for class [%s]", cc.ScFlag, cc.ClassName)
+			refs.logger().Error("[JavaTcObject] Unexpected SC_FLAG [0x%x] for class [%s]", cc.ScFlag, cc.ClassName)
 		}
 	}
 
 	class0 := jo.Classes[0]
 	switch class0.SerialVersionUID {
 	case SID_BYTE, SID_SHORT, SID_BOOLEAN, SID_CHARACTER, SID_INTEGER, SID_LONG, SID_FLOAT, SID_DOUBLE:
-		jf0 := class0.Fields[0]
-		if jf0.FieldName != "value" {
-			return fmt.Errorf("8 base type Object, field name should be value, but %s", jf0.FieldName)
+		if len(class0.Fields) == 0 || class0.Fields[0].FieldName != "value" {
+			return fmt.Errorf("8 base type Object, field name should be value, but %v", class0.Fields)
+		}
+	}
+	return nil
+}
+
+//cloneClassDescChain copy the classDesc chain for one object, so that field values and RwDatas are not shared by the objects of the same class
+//the copies have the same name & serialVersionUID, they are still written as TC_REFERENCE to the first one
+func cloneClassDescChain(classes []*JavaTcClassDesc) []*JavaTcClassDesc {
+	clones := make([]*JavaTcClassDesc, len(classes))
+	for i := len(classes) - 1; i >= 0; i-- {
+		cd := *classes[i]
+		cd.Fields = make([]*JavaField, len(classes[i].Fields))
+		for j, jf := range classes[i].Fields {
+			f := *jf
+			f.FieldValue = nil
+			cd.Fields[j] = &f
+		}
+		cd.RwDatas = nil
+		if i+1 < len(classes) {
+			cd.SuperClass = clones[i+1]
 		}
-		jo.JsonData = jf0.FieldValue
+		clones[i] = &cd
+	}
+	return clones
+}
+
+//JsonMap return json style data, map for general object, the value itself for the 8 boxed types
+//cyclic references are replaced by {"__ref__": path}
+func (jo *JavaTcObject) JsonMap() interface{} {
+	return newJsonVisitor().value(jo, "$")
+}
+
+func (jo *JavaTcObject) jsonMap(v *jsonVisitor, path string) interface{} {
+	if len(jo.Classes) == 0 {
 		return nil
 	}
+	class0 := jo.Classes[0]
+	switch class0.SerialVersionUID {
+	case SID_BYTE, SID_SHORT, SID_BOOLEAN, SID_CHARACTER, SID_INTEGER, SID_LONG, SID_FLOAT, SID_DOUBLE:
+		if len(class0.Fields) > 0 {
+			return class0.Fields[0].FieldValue
+		}
+	}
 	//otherwise is general object
+	if marker, ok := v.enter(jo, path); !ok {
+		return marker
+	}
+	defer v.leave(jo)
 
 	jsonDatas := make(map[string]interface{})
-	jo.JsonData = jsonDatas
 	for i, clazz := range jo.Classes {
 		jsonDatas[fmt.Sprintf("__class__%d", len(jo.Classes)-i-1)] = clazz.ClassName
-		if clazz.ScFlag == SC_RW_OBJECT {
-			rwVal := clazz.RwDatas[0]
-			if js, ok := rwVal.(JavaSerializer); ok {
-				jsVal := js.JsonMap()
-				if mp, ok := jsVal.(map[string]interface{}); ok {
-					for k, v := range mp {
-						jsonDatas[k] = v
-					}
+		if clazz.IsProxy {
+			jsonDatas["__proxy__"] = clazz.ProxyInterfaces
+		}
+	}
+	if class0.IsExternalizable() {
+		ext := jo.rwObject(0)
+		if ext == nil {
+			ext = &JavaExternalizable{ClassDesc: class0}
+		}
+		jsonDatas["__external__"] = v.value(ext, path+".__external__")
+		return jsonDatas
+	}
+	for i, clazz := range jo.Classes {
+		if clazz.HasWriteMethod() {
+			js := jo.rwObject(i)
+			if js == nil { //no classdata read for SC_RW_OBJECT, e.g. built by hand
+				continue
+			}
+			if rw, ok := js.(*JavaRwObject); ok {
+				key := fmt.Sprintf("__annotations__%d", len(jo.Classes)-i-1)
+				jsonDatas[key] = v.values(rw.ClassDesc.RwDatas, path+"."+key)
+			}
+			if mp, ok := v.value(js, path).(map[string]interface{}); ok {
+				for k, val := range mp {
+					jsonDatas[k] = val
 				}
-			} else {
-				StdLogger.Warn("[JavaTcObject] Deserialize Expect JavaSerializer for SC_RW_OBJECT,but got %v\n", rwVal)
 			}
 			continue
 		}
 		for _, jf := range clazz.Fields {
-			fv := jf.FieldValue
-			if js, ok := fv.(JavaSerializer); ok {
-				jsonDatas[jf.FieldName] = js.JsonMap()
-			} else {
-				jsonDatas[jf.FieldName] = fv
-			}
+			jsonDatas[jf.FieldName] = v.value(jf.FieldValue, path+"."+jf.FieldName)
 		}
 	}
-
-	return nil
+	return jsonDatas
 }
 
-//JsonMap return jsonmap
-func (jo *JavaTcObject) JsonMap() interface{} {
-	return jo.JsonData
+//rwObject return the classdata of the i-th class read by custom reader, nil if there is not
+func (jo *JavaTcObject) rwObject(i int) JavaSerializer {
+	if i < len(jo.rwObjects) {
+		return jo.rwObjects[i]
+	}
+	return nil
 }
 
 //Serialize serialize JavaTcObject to stream
-func (jo *JavaTcObject) Serialize(writer io.Writer, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcObject] Serialize >> \n")
-	defer StdLogger.Debug("[JavaTcObject] Serialize << \n")
+func (jo *JavaTcObject) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcObject] Serialize >> \n")
+	defer refs.logger().Debug("[JavaTcObject] Serialize << \n")
 
 	//make sure the SerialVersionUID
 	if jo.SerialVersionUID == 0 {
@@ -596,35 +773,11 @@ func (jo *JavaTcObject) Serialize(writer io.Writer, refs []*JavaReferenceObject)
 
 	buff := make([]byte, 8)
 	var err error
-	var refIndex int = -1
-	//first judge if there is TC_REF already
-	for i := 0; i < len(refs); i += 1 {
-		var ref interface{} = refs[i]
-		if refs[i] == nil {
-			break
-		}
-		if jot, ok := ref.(*JavaTcObject); !ok {
-			continue
-		} else if jot.SerialVersionUID != jo.SerialVersionUID {
-			continue
-		} else {
-			//judge the json
-			if this0, err := json.Marshal(jo); err != nil {
-				return err
-			} else if this1, err := json.Marshal(jot); err != nil {
-				return err
-			} else if bytes.Equal(this0, this1) {
-				refIndex = i
-				break
-			}
-		}
-	}
-
-	if refIndex >= 0 {
+	//first judge if there is TC_REF already, the same *JavaTcObject means the same java object
+	if refIndex, found := refs.Lookup(jo); found {
 		buff[0] = TC_REFERENCE
-		refTotal := INTBASE_WIRE_HANDLE + uint32(refIndex)
-		binary.BigEndian.PutUint32(buff[1:5], refTotal)
-		StdLogger.Debug("[JavaTcObject] Serialize got Reference %d \n", refIndex)
+		binary.BigEndian.PutUint32(buff[1:5], refIndex)
+		refs.logger().Debug("[JavaTcObject] Serialize got Reference 0x%x \n", refIndex)
 		_, err = writer.Write(buff[:5])
 		return err
 	}
@@ -635,29 +788,33 @@ func (jo *JavaTcObject) Serialize(writer io.Writer, refs []*JavaReferenceObject)
 		return err
 	}
 
-	for _, cs := range jo.Classes {
-		if err = cs.Serialize(writer, refs); err != nil {
-			return err
-		}
-	}
 	//类，包括父类写完后 write TC_NULL
-	buff[0] = TC_NULL
-	if _, err = writer.Write(buff[:1]); err != nil {
+	if err = SerializeClassDescChain(writer, refs, jo.Classes); err != nil {
 		return err
 	}
 	//add reference
 	AddReference(refs, TC_OBJECT, jo)
+	if err = refs.enter(); err != nil {
+		return err
+	}
+	defer refs.leave()
+	if jo.Classes[0].IsExternalizable() {
+		ext := &JavaExternalizable{
+			ClassDesc: jo.Classes[0],
+		}
+		return ext.Serialize(writer, refs)
+	}
 	// classDesc 有多个，注意每一层classdesc要区分SC_FLAG, 只针对 SC_RW_OBJECT的调用个性化的
 	for i := len(jo.Classes) - 1; i >= 0; i -= 1 {
 		cc := jo.Classes[i]
-		//if SC_FLAG equals 0x03, we invoke the custom serializer
-		if cc.ScFlag == SC_RW_OBJECT {
+		//if SC_FLAG has SC_WRITE_METHOD, we invoke the custom serializer
+		if cc.HasWriteMethod() {
 			if err = SerializeScRwObject(writer, refs, cc); err != nil {
 				return err
 			}
 		} else {
 			for j, jf := range cc.Fields {
-				StdLogger.Debug("[JavaTcObject] Serialize JavaField (%d,%d) %s \n", i, j, jf.FieldName)
+				refs.logger().Debug("[JavaTcObject] Serialize JavaField (%d,%d) %s \n", i, j, jf.FieldName)
 				if err = SerializeJavaField(jf, writer, refs); err != nil {
 					return err
 				}
@@ -670,14 +827,18 @@ func (jo *JavaTcObject) Serialize(writer io.Writer, refs []*JavaReferenceObject)
 }
 
 //SerializeJavaField 注意是序列化它的值，而不是描述符
-func SerializeJavaField(jf *JavaField, writer io.Writer, refs []*JavaReferenceObject) error {
+func SerializeJavaField(jf *JavaField, writer io.Writer, refs *JavaReferencePool) error {
 	buff := make([]byte, 8)
 	var err error
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[SerializeJavaField] %s >> \n", jf.FieldName)
-	defer StdLogger.Debug("[SerializeJavaField] %s << \n", jf.FieldName)
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[SerializeJavaField] %s >> \n", jf.FieldName)
+	defer refs.logger().Debug("[SerializeJavaField] %s << \n", jf.FieldName)
 	v := jf.FieldValue
+	if v == nil && (jf.FieldType == TC_OBJ_OBJECT || jf.FieldType == TC_OBJ_ARRAY) {
+		_, err = writer.Write([]byte{TC_NULL})
+		return err
+	}
 	switch jf.FieldType {
 	case TC_PRIM_BYTE:
 		if b, ok := v.(byte); !ok {
@@ -776,6 +937,14 @@ func SerializeJavaField(jf *JavaField, writer io.Writer, refs []*JavaReferenceOb
 				if err = tstr.Serialize(writer, refs); err != nil {
 					return err
 				}
+			} else if tcEnum, ok := v.(*JavaTcEnum); ok {
+				if err = tcEnum.Serialize(writer, refs); err != nil {
+					return err
+				}
+			} else if tcClass, ok := v.(*JavaTcClass); ok {
+				if err = tcClass.Serialize(writer, refs); err != nil {
+					return err
+				}
 			} else {
 				return fmt.Errorf("Expect JavaTcObject for TC_OBJ_OBJECT, but got %v", v)
 			}
@@ -796,187 +965,191 @@ func SerializeJavaField(jf *JavaField, writer io.Writer, refs []*JavaReferenceOb
 	return err
 }
 
+//ReadTcArray read next TC_ARRAY, TC_REFERENCE or TC_NULL from the stream, nil for TC_NULL
+//every TC_REFERENCE to one handle returns the same *JavaTcArray, even if the array is still being read
+func ReadTcArray(reader io.Reader, refs *JavaReferencePool) (*JavaTcArray, error) {
+	js, err := readNextEleOf(TC_ARRAY, reader, refs)
+	if err != nil || js == nil {
+		return nil, err
+	} else if tcArr, ok := js.(*JavaTcArray); ok {
+		return tcArr, nil
+	}
+	return nil, fmt.Errorf("%w: [JavaTcArray] Expect *JavaTcArray, but got %T", ErrBadReference, js)
+}
+
 //Deserialize JavaTcArray deserialize
+//if the stream is TC_REFERENCE to an array read before, the class desc & values of that array are copied into tcArr,
+//so tcArr is a distinct array of the same java identity
 //davidwang2006@aliyun.com
 //2018-01-31 16:37:30
-func (tcArr *JavaTcArray) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
+//
+//Deprecated: use ReadTcArray, which returns the shared *JavaTcArray for TC_REFERENCE.
+func (tcArr *JavaTcArray) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
 	//TC_ARRAY开头
 	//兼容这个TC_ARRAY是否被消费掉
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcArray] >> ++BEGIN\n")
-	defer StdLogger.Debug("[JavaTcArray] << --END\n")
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcArray] >> ++BEGIN\n")
+	defer refs.logger().Debug("[JavaTcArray] << --END\n")
 	//firstly, analysis all tc_classdesc
 	//TC_OBJECT
 	var buff = make([]byte, 4)
 	var err error
-	if _, err = reader.Read(buff[:1]); err != nil {
+	if _, err = io.ReadFull(reader, buff[:1]); err != nil {
 		return err
 	}
 	if TC_REFERENCE == buff[0] { //表示引用了另一个CLASSDESC
-		//读剩下的3个字节，后二个字节表示refIndex
-		if _, err = reader.Read(buff[:4]); err != nil {
+		//读剩下的4个字节，表示handle
+		if refIndex, err := ReadUint32(reader); err != nil {
 			return err
 		} else {
-			refIndex := binary.BigEndian.Uint16(buff[2:4])
-			ref := refs[int(refIndex)]
-			if ref.RefType != TC_ARRAY {
-				return fmt.Errorf("[JavaTcArray] Expect ref [%d] type TC_OBJECT, but 0x%x", refIndex, ref.RefType)
+			if ref, err := refs.Get(refIndex); err != nil {
+				return err
+			} else if ref.RefType != TC_ARRAY {
+				return fmt.Errorf("%w: [JavaTcArray] Expect ref [0x%x] type TC_ARRAY, but 0x%x", ErrBadReference, refIndex, ref.RefType)
 			} else if jarrp, ok := ref.Val.(*JavaTcArray); !ok {
-				return fmt.Errorf("[JavaTcArray] Expect ref[%d] val *JavaTcObject, but %v", refIndex, ref.Val)
+				return fmt.Errorf("%w: [JavaTcArray] Expect ref [0x%x] val *JavaTcArray, but %v", ErrBadReference, refIndex, ref.Val)
 			} else {
 				tcArr.ClassDesc = jarrp.ClassDesc
 				tcArr.SerialVersionUID = jarrp.SerialVersionUID
 				tcArr.Values = jarrp.Values
+				tcArr.Prims = jarrp.Prims
 				return nil
 			}
 		}
 	} else if TC_ARRAY == buff[0] { //证明开头的tc_array未被消费，则再读下一个
-		if _, err = reader.Read(buff[:1]); err != nil {
+		if _, err = io.ReadFull(reader, buff[:1]); err != nil {
 			return err
 		}
 	}
+	return tcArr.deserializeBody(buff[0], reader, refs)
+}
 
+//deserializeBody read classDesc, newHandle and the values, TC_ARRAY has been consumed already
+//b is the first byte of classDesc, TC_CLASSDESC or TC_REFERENCE to classDesc
+func (tcArr *JavaTcArray) deserializeBody(b byte, reader io.Reader, refs *JavaReferencePool) error {
+	if err := refs.enter(); err != nil {
+		return err
+	}
+	defer refs.leave()
 	//now begin tc_classdesc
-	//TC_ARRAY只有一个TC_CLASSDESC
-	tcArr.ClassDesc = &JavaTcClassDesc{}
-	if err = tcArr.ClassDesc.Deserialize(reader, refs); err != nil {
+	//TC_ARRAY只有一个TC_CLASSDESC, 其后为TC_NULL; 若为TC_REFERENCE则没有TC_NULL
+	if classes, err := ReadClassDescChain(b, reader, refs); err != nil {
 		return err
+	} else if len(classes) != 1 {
+		return fmt.Errorf("Expect only one TC_CLASSDESC in TC_ARRAY header, but got %d", len(classes))
+	} else {
+		tcArr.ClassDesc = classes[0]
 	}
 	tcArr.SerialVersionUID = tcArr.ClassDesc.SerialVersionUID
 	//TC_ARRAY newHandle should added
 	AddReference(refs, TC_ARRAY, tcArr)
-	//Next should be TC_NULL 0x70
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != TC_NULL {
-		return fmt.Errorf("Expect TC_NULL after TC_CLASSDESC in TC_ARRAY header, but got 0x%x", b)
-	}
 
 	var elementCount int
 	if b, err := ReadUint32(reader); err != nil {
 		return err
+	} else if b > math.MaxInt32 {
+		//java数组长度为int, 负数说明数据已损坏
+		return refs.decodeError(fmt.Errorf("[JavaTcArray] Illegal size %d of %s", int32(b), tcArr.ClassDesc.ClassName))
 	} else {
-		StdLogger.Debug("[JavaTcArray] [%s] has %d elements\n", tcArr.ClassDesc.ClassName, b)
+		refs.logger().Debug("[JavaTcArray] [%s] has %d elements\n", tcArr.ClassDesc.ClassName, b)
 		elementCount = int(b)
 	}
 
 	//分析className的第二个字节，看是8大基本类型还是'L'
 	//String的话 className is [Ljava.lang.String;
 	classNameArr := ([]byte)(tcArr.ClassDesc.ClassName)
+	//至少为 [ 加元素类型, 对象元素为 [Lxxx;
+	if n := len(classNameArr); n < 2 || classNameArr[0] != TC_OBJ_ARRAY || classNameArr[1] == TC_OBJ_OBJECT && (n < 3 || classNameArr[n-1] != ';') {
+		return refs.decodeError(fmt.Errorf("%w: [JavaTcArray] Illegal array class name %q", ErrBadReference, tcArr.ClassDesc.ClassName))
+	}
 	eleType := (classNameArr)[1]
 
-	tcArr.Values = make([]interface{}, 0, elementCount)
-	tcArr.JsonData = make([]interface{}, 0, elementCount)
+	switch {
+	case IsPrimType(eleType), eleType == TC_OBJ_ARRAY, eleType == TC_OBJ_OBJECT:
+	case refs.strict():
+		return fmt.Errorf("%w: [JavaTcArray] unexpected element type 0x%x of %s", ErrUnsupportedClass, eleType, tcArr.ClassDesc.ClassName)
+	default:
+		refs.logger().Error("[JavaTcArray] unexpected element type %v\n", eleType)
+		return nil
+	}
 
-	for i := 0; i < elementCount; i++ {
-		switch eleType {
-		case TC_PRIM_BYTE:
-			if b, err := ReadNextByte(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, b)
-				tcArr.JsonData = append(tcArr.JsonData, b)
-			}
-		case TC_PRIM_BOOLEAN:
-			if b, err := ReadNextByte(reader); err != nil {
-				return err
-			} else {
-				bv := b == 1
-				tcArr.Values = append(tcArr.Values, bv)
-				tcArr.JsonData = append(tcArr.JsonData, b)
-			}
-		case TC_PRIM_CHAR:
-			if b, err := ReadUint16(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, rune(b))
-				tcArr.JsonData = append(tcArr.JsonData, rune(b))
-			}
-		case TC_PRIM_SHORT:
-			if b, err := ReadUint16(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, b)
-				tcArr.JsonData = append(tcArr.JsonData, b)
-			}
-		case TC_PRIM_INTEGER:
-			if b, err := ReadUint32(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, b)
-				tcArr.JsonData = append(tcArr.JsonData, b)
-			}
-		case TC_PRIM_LONG:
-			if b, err := ReadUint64(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, b)
-				tcArr.JsonData = append(tcArr.JsonData, b)
-			}
-		case TC_PRIM_FLOAT:
-			if b, err := ReadUint32(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, float32(b))
-				tcArr.JsonData = append(tcArr.JsonData, float32(b))
-			}
-		case TC_PRIM_DOUBLE:
-			if b, err := ReadUint64(reader); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, float64(b))
-				tcArr.JsonData = append(tcArr.JsonData, float64(b))
-			}
-		case TC_OBJ_ARRAY: //也有可能是数组
-			StdLogger.Debug("[JavaTcArray] element[%d] is array too\n", i)
-			subArray := &JavaTcArray{}
-			if err := subArray.Deserialize(reader, refs); err != nil {
-				return err
-			} else {
-				tcArr.Values = append(tcArr.Values, subArray)
-				tcArr.JsonData = append(tcArr.JsonData, subArray.JsonData)
-			}
-		case TC_OBJ_OBJECT:
-			elementClassName := string(classNameArr[2 : len(classNameArr)-1])
-			StdLogger.Debug("[JavaTcArray] element[%d] className is %s\n", i, elementClassName)
-			if elementClassName == "java.lang.String" {
-				if str, err := ReadNextTcString(reader, refs); err != nil {
-					return err
-				} else {
-					tcArr.Values = append(tcArr.Values, str)
-					tcArr.JsonData = append(tcArr.JsonData, str)
-				}
-			} else {
-				jo := &JavaTcObject{}
-				if err := jo.Deserialize(reader, refs); err != nil {
-					return err
-				} else {
-					tcArr.Values = append(tcArr.Values, jo)
-					tcArr.JsonData = append(tcArr.JsonData, jo.JsonData)
-				}
-			}
-		default:
-			StdLogger.Error("[JavaTcArray] element[%d] unexpected type %v\n", i, eleType)
+	if IsPrimType(eleType) {
+		var err error
+		if tcArr.Prims, err = readPrimArray(eleType, elementCount, reader); err != nil {
+			return refs.decodeError(err)
 		}
+		return nil
+	}
 
+	tcArr.Values = make([]interface{}, 0, min(elementCount, MAX_PREALLOC_ELEMENTS))
+	refs.pushIndex()
+	defer refs.popPath()
+	for i := 0; i < elementCount; i++ {
+		refs.setIndex(i)
+		if v, err := readArrayElement(eleType, classNameArr, reader, refs); err != nil {
+			return refs.decodeError(err)
+		} else {
+			tcArr.Values = append(tcArr.Values, v)
+		}
 	}
 
 	return nil
 
 }
 
-//JsonMap return jsonmap
+//readArrayElement read one element of the array, classNameArr is the class name of the array
+func readArrayElement(eleType byte, classNameArr []byte, reader io.Reader, refs *JavaReferencePool) (interface{}, error) {
+	switch eleType {
+	case TC_PRIM_BOOLEAN:
+		if b, err := ReadNextByte(reader); err != nil {
+			return nil, err
+		} else {
+			return b == 1, nil
+		}
+	case TC_PRIM_CHAR:
+		if b, err := ReadUint16(reader); err != nil {
+			return nil, err
+		} else {
+			return rune(b), nil
+		}
+	case TC_OBJ_ARRAY: //也有可能是数组
+		//TC_ARRAY, TC_REFERENCE or TC_NULL
+		return ReadTcArrayFieldValue(eleType, string(classNameArr[1:]), reader, refs)
+	case TC_OBJ_OBJECT:
+		elementClassName := string(classNameArr[2 : len(classNameArr)-1])
+		if elementClassName == "java.lang.String" {
+			return ReadNextTcString(reader, refs)
+		}
+		//TC_OBJECT, TC_ENUM, TC_REFERENCE or TC_NULL
+		return ReadNextEle(reader, refs)
+	}
+	//其他基本类型与field的值相同
+	return ReadTcPrimFieldValue(eleType, reader)
+}
+
+//JsonMap return the json style data of the elements, cyclic references are replaced by {"__ref__": path}
 func (tcArr *JavaTcArray) JsonMap() interface{} {
-	return tcArr.JsonData
+	return newJsonVisitor().value(tcArr, "$")
+}
+
+func (tcArr *JavaTcArray) jsonMap(v *jsonVisitor, path string) interface{} {
+	if tcArr.Prims != nil {
+		return primArrayValues(tcArr.Prims)
+	}
+	if marker, ok := v.enter(tcArr, path); !ok {
+		return marker
+	}
+	defer v.leave(tcArr)
+	return v.values(tcArr.Values, path)
 }
 
 //Serialize JavaTcArray serialize it out to stream
-func (tcArr *JavaTcArray) Serialize(writer io.Writer, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaTcArray] Serialize >> \n")
-	defer StdLogger.Debug("[JavaTcArray] Serialize << \n")
+func (tcArr *JavaTcArray) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcArray] Serialize >> \n")
+	defer refs.logger().Debug("[JavaTcArray] Serialize << \n")
 
 	//make sure the SerialVersionUID
 	if tcArr.SerialVersionUID == 0 {
@@ -987,57 +1160,47 @@ func (tcArr *JavaTcArray) Serialize(writer io.Writer, refs []*JavaReferenceObjec
 
 	buff := make([]byte, 8)
 	var err error
-	var refIndex int = -1
-	//first judge if there is TC_REF already
-	for i := 0; i < len(refs); i += 1 {
-		var ref interface{} = refs[i]
-		if refs[i] == nil {
-			break
-		}
-		if refs[i].RefType != TC_ARRAY {
-			continue
-		} else if jArrP, ok := ref.(*JavaTcArray); !ok {
-			continue
-		} else if jArrP.SerialVersionUID != tcArr.SerialVersionUID {
-			continue
-		} else {
-			//judge the json
-			if this0, err := json.Marshal(tcArr); err != nil {
-				return err
-			} else if this1, err := json.Marshal(jArrP); err != nil {
-				return err
-			} else if bytes.Equal(this0, this1) {
-				refIndex = i
-				break
-			}
-		}
-	}
-
-	if refIndex >= 0 {
+	//first judge if there is TC_REF already, the same *JavaTcArray means the same java array
+	if refIndex, found := refs.Lookup(tcArr); found {
 		buff[0] = TC_REFERENCE
-		refTotal := INTBASE_WIRE_HANDLE + uint32(refIndex)
-		binary.BigEndian.PutUint32(buff[1:5], refTotal)
-		StdLogger.Debug("[JavaTcArray] Serialize got Reference %d \n", refIndex)
+		binary.BigEndian.PutUint32(buff[1:5], refIndex)
+		refs.logger().Debug("[JavaTcArray] Serialize got Reference 0x%x \n", refIndex)
 		_, err = writer.Write(buff[:5])
 		return err
 	}
 
+	//基本类型数组先整块编码, 元素与ClassName不符时什么都不写
+	var prims []byte
+	eleType, isPrim := primArrayType(tcArr.ClassDesc.ClassName)
+	if isPrim {
+		if prims, err = tcArr.encodePrims(eleType); err != nil {
+			return err
+		}
+	}
+
 	//没有ref，开始写TcObject
 	buff[0] = TC_ARRAY
 	if _, err = writer.Write(buff[:1]); err != nil { // TC_ARRAY
 		return err
 	}
-	//TC_CLASSDESC
-	if err = tcArr.ClassDesc.Serialize(writer, refs); err != nil {
-		return err
-	}
-	//类，写完后 write TC_NULL
-	buff[0] = TC_NULL
-	if _, err = writer.Write(buff[:1]); err != nil {
+	//TC_CLASSDESC, 类写完后 write TC_NULL
+	if err = SerializeClassDescChain(writer, refs, []*JavaTcClassDesc{tcArr.ClassDesc}); err != nil {
 		return err
 	}
 	//add reference
 	AddReference(refs, TC_ARRAY, tcArr)
+	if err = refs.enter(); err != nil {
+		return err
+	}
+	defer refs.leave()
+	if isPrim {
+		binary.BigEndian.PutUint32(buff[:4], uint32(len(prims)/primSize(eleType)))
+		if _, err = writer.Write(buff[:4]); err != nil {
+			return err
+		}
+		_, err = writer.Write(prims)
+		return err
+	}
 	//read elements count
 	eleCount := len(tcArr.Values)
 	binary.BigEndian.PutUint32(buff[:4], uint32(eleCount))
@@ -1052,52 +1215,15 @@ func (tcArr *JavaTcArray) Serialize(writer io.Writer, refs []*JavaReferenceObjec
 		var rvType string
 		if rv.Kind() == reflect.Ptr {
 			rvType = rv.Elem().Type().Name()
-		} else {
+		} else if rv.IsValid() {
 			rvType = rv.Type().Name()
 		}
-		StdLogger.Debug("[JavaTcArray] Serialize eles[%d][type=%s] %v >> \n", i, rvType, ev)
-		if it, ok := ev.(int); ok {
-			binary.BigEndian.PutUint32(buff[:4], uint32(it))
-			if _, err = writer.Write(buff[:4]); err != nil {
-				return err
-			}
-		} else if i8, ok := ev.(uint8); ok {
-			buff[0] = i8
+		refs.logger().Debug("[JavaTcArray] Serialize eles[%d][type=%s] %v >> \n", i, rvType, ev)
+		if ev == nil {
+			buff[0] = TC_NULL
 			if _, err = writer.Write(buff[:1]); err != nil {
 				return err
 			}
-		} else if b, ok := ev.(byte); ok {
-			buff[0] = b
-			if _, err = writer.Write(buff[:1]); err != nil {
-				return err
-			}
-		} else if i16, ok := ev.(uint16); ok {
-			binary.BigEndian.PutUint16(buff[:2], i16)
-			if _, err = writer.Write(buff[:2]); err != nil {
-				return err
-			}
-		} else if i32, ok := ev.(uint32); ok {
-			binary.BigEndian.PutUint32(buff[:4], i32)
-			if _, err = writer.Write(buff[:4]); err != nil {
-				return err
-			}
-		} else if i64, ok := ev.(uint64); ok {
-			binary.BigEndian.PutUint64(buff[:8], i64)
-			if _, err = writer.Write(buff[:8]); err != nil {
-				return err
-			}
-		} else if f32, ok := ev.(float32); ok {
-			i32 := math.Float32bits(f32)
-			binary.BigEndian.PutUint32(buff[:4], i32)
-			if _, err = writer.Write(buff[:4]); err != nil {
-				return err
-			}
-		} else if f64, ok := ev.(float64); ok {
-			i64 := math.Float64bits(f64)
-			binary.BigEndian.PutUint64(buff[:8], i64)
-			if _, err = writer.Write(buff[:8]); err != nil {
-				return err
-			}
 		} else if str, ok := ev.(string); ok {
 			var tcStr = new(JavaTcString)
 			*tcStr = (JavaTcString)(str)
@@ -1116,8 +1242,16 @@ func (tcArr *JavaTcArray) Serialize(writer io.Writer, refs []*JavaReferenceObjec
 			if err = tcArr_.Serialize(writer, refs); err != nil {
 				return err
 			}
+		} else if tcEnum, ok := ev.(*JavaTcEnum); ok {
+			if err = tcEnum.Serialize(writer, refs); err != nil {
+				return err
+			}
+		} else if tcClass, ok := ev.(*JavaTcClass); ok {
+			if err = tcClass.Serialize(writer, refs); err != nil {
+				return err
+			}
 		} else {
-			StdLogger.Error("[JavaTcArray] Serialize unexpected eles[%d][type=%s] %v >> \n", i, rvType, ev)
+			refs.logger().Error("[JavaTcArray] Serialize unexpected eles[%d][type=%s] %v >> \n", i, rvType, ev)
 			return fmt.Errorf("[JavaTcArray] Serialize unexpected eles[%d][type=%s] %v >> \n", i, rvType, ev)
 		}
 	}
@@ -1125,6 +1259,19 @@ func (tcArr *JavaTcArray) Serialize(writer io.Writer, refs []*JavaReferenceObjec
 	return nil
 }
 
+//encodePrims encode the elements of primitive array in big endian
+//the elements are Prims, or Values converted to the typed slice of eleType if Prims is nil
+func (tcArr *JavaTcArray) encodePrims(eleType byte) ([]byte, error) {
+	prims := tcArr.Prims
+	if prims == nil {
+		var err error
+		if prims, err = primArrayOf(eleType, tcArr.Values); err != nil {
+			return nil, fmt.Errorf("%w of %s", err, tcArr.ClassDesc.ClassName)
+		}
+	}
+	return encodePrimArray(eleType, prims)
+}
+
 //AddClassDesc add java tc class desc to javatcobject
 func (jo *JavaTcObject) AddClassDesc(jcd *JavaTcClassDesc) {
 	jo.Classes = append(jo.Classes, jcd)
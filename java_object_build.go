@@ -1,4 +1,8 @@
-package main
+package javaserializer
+
+import "bytes"
+import "crypto/sha1"
+import "encoding/binary"
 
 //NewJavaTcArray new java tc array
 func NewJavaTcArray(serialVersionUID uint64) *JavaTcArray {
@@ -57,18 +61,54 @@ func NewJavaTcString(str string) *JavaTcString {
 	return jts
 }
 
-//NewByteArray
-func NewByteArray(items []byte) *JavaTcArray {
-	jArr := NewJavaTcArray(uint64(SID_BYTE_ARRAY))
-	for _, c := range items {
-		jArr.Values = append(jArr.Values, c)
+//newJavaPrimArray new java primitive array, prims is kept as the backing slice
+func newJavaPrimArray(className string, serialVersionUID uint64, prims interface{}) *JavaTcArray {
+	jArr := &JavaTcArray{
+		SerialVersionUID: serialVersionUID,
+		Prims:            prims,
 	}
+	jArr.ClassDesc = NewJavaTcClassDesc(className, serialVersionUID, SC_SERIALIZABLE)
+	return jArr
+}
 
-	clz := NewJavaTcClassDesc("[B", uint64(SID_BYTE_ARRAY), SC_SERIALIZABLE)
-	jArr.ClassDesc = clz
+//NewBooleanArray new java boolean[]
+func NewBooleanArray(items []bool) *JavaTcArray {
+	return newJavaPrimArray("[Z", SID_BOOLEAN_ARRAY, items)
+}
 
-	return jArr
+//NewByteArray new java byte[]
+func NewByteArray(items []byte) *JavaTcArray {
+	return newJavaPrimArray("[B", SID_BYTE_ARRAY, items)
+}
 
+//NewCharArray new java char[], the items are UTF-16 units
+func NewCharArray(items []uint16) *JavaTcArray {
+	return newJavaPrimArray("[C", SID_CHAR_ARRAY, items)
+}
+
+//NewShortArray new java short[]
+func NewShortArray(items []int16) *JavaTcArray {
+	return newJavaPrimArray("[S", SID_SHORT_ARRAY, items)
+}
+
+//NewIntArray new java int[]
+func NewIntArray(items []int32) *JavaTcArray {
+	return newJavaPrimArray("[I", SID_INT_ARRAY, items)
+}
+
+//NewLongArray new java long[]
+func NewLongArray(items []int64) *JavaTcArray {
+	return newJavaPrimArray("[J", SID_LONG_ARRAY, items)
+}
+
+//NewFloatArray new java float[]
+func NewFloatArray(items []float32) *JavaTcArray {
+	return newJavaPrimArray("[F", SID_FLOAT_ARRAY, items)
+}
+
+//NewDoubleArray new java double[]
+func NewDoubleArray(items []float64) *JavaTcArray {
+	return newJavaPrimArray("[D", SID_DOUBLE_ARRAY, items)
 }
 
 //NewStringArray
@@ -84,3 +124,95 @@ func NewStringArray(items []string) *JavaTcArray {
 	return jArr
 
 }
+
+//NewJavaTcEnum new java enum constant, className is the enum class name, e.g. java.util.concurrent.TimeUnit
+func NewJavaTcEnum(className string, constantName string) *JavaTcEnum {
+	tcEnum := &JavaTcEnum{
+		ConstantName: constantName,
+	}
+	tcEnum.Classes = []*JavaTcClassDesc{
+		NewJavaTcClassDesc(className, 0, SC_SERIALIZABLE|SC_ENUM),
+		NewJavaTcClassDesc("java.lang.Enum", 0, SC_SERIALIZABLE|SC_ENUM),
+	}
+	tcEnum.Classes[0].SuperClass = tcEnum.Classes[1]
+	return tcEnum
+}
+
+//NewJavaTcClass new java.lang.Class value, use scFlag 0x00 for classes not serializable, e.g. int.class
+func NewJavaTcClass(className string, serialVersionUID uint64, scFlag byte) *JavaTcClass {
+	return &JavaTcClass{
+		ClassDesc: NewJavaTcClassDesc(className, serialVersionUID, scFlag),
+	}
+}
+
+//NewJavaProxyObject new dynamic proxy object implements interfaces, handler is the InvocationHandler
+func NewJavaProxyObject(interfaces []string, handler *JavaTcObject) *JavaTcObject {
+	proxyClz := &JavaTcClassDesc{
+		ScFlag:          SC_SERIALIZABLE,
+		Fields:          make([]*JavaField, 0),
+		IsProxy:         true,
+		ProxyInterfaces: interfaces,
+	}
+	clz := NewJavaTcClassDesc("java.lang.reflect.Proxy", SID_PROXY, SC_SERIALIZABLE)
+	clz.AddField(NewObjectJavaField("java.lang.reflect.InvocationHandler", "h", handler))
+	proxyClz.SuperClass = clz
+
+	jo := NewJavaTcObject(0)
+	jo.AddClassDesc(proxyClz)
+	jo.AddClassDesc(clz)
+	return jo
+}
+
+//SID_NUMBER serialVersionUID of java.lang.Number, the super class of Integer, Long etc.
+const SID_NUMBER uint64 = 0x86AC951D0B94E08B
+
+//boxedClasses java.lang.Integer etc., by the prim typecode of its value field
+var boxedClasses = map[byte]struct {
+	className        string
+	serialVersionUID uint64
+}{
+	TC_PRIM_BYTE:    {"java.lang.Byte", SID_BYTE},
+	TC_PRIM_SHORT:   {"java.lang.Short", SID_SHORT},
+	TC_PRIM_INTEGER: {"java.lang.Integer", SID_INTEGER},
+	TC_PRIM_LONG:    {"java.lang.Long", SID_LONG},
+	TC_PRIM_FLOAT:   {"java.lang.Float", SID_FLOAT},
+	TC_PRIM_DOUBLE:  {"java.lang.Double", SID_DOUBLE},
+	TC_PRIM_BOOLEAN: {"java.lang.Boolean", SID_BOOLEAN},
+	TC_PRIM_CHAR:    {"java.lang.Character", SID_CHARACTER},
+}
+
+//NewBoxedObject new java.lang.Integer, java.lang.Long etc. by the prim typecode, nil for unknown typecode
+//value is the same as JavaField.FieldValue of the prim typecode, e.g. uint32 for TC_PRIM_INTEGER
+func NewBoxedObject(fieldType byte, value interface{}) *JavaTcObject {
+	boxed, ok := boxedClasses[fieldType]
+	if !ok {
+		return nil
+	}
+	jo := NewJavaTcObject(boxed.serialVersionUID)
+	clz := NewJavaTcClassDesc(boxed.className, boxed.serialVersionUID, SC_SERIALIZABLE)
+	clz.AddField(NewJavaField(fieldType, "value", value))
+	jo.AddClassDesc(clz)
+	//Boolean, Character 直接继承Object
+	if fieldType != TC_PRIM_BOOLEAN && fieldType != TC_PRIM_CHAR {
+		number := NewJavaTcClassDesc("java.lang.Number", SID_NUMBER, SC_SERIALIZABLE)
+		clz.SuperClass = number
+		jo.AddClassDesc(number)
+	}
+	return jo
+}
+
+//ArraySerialVersionUID compute the default serialVersionUID of array class, e.g. [I, [Ljava.lang.String;
+//aka ObjectStreamClass.computeDefaultSUID, array class has only its name and the modifiers public|final|abstract
+func ArraySerialVersionUID(className string) uint64 {
+	buff := new(bytes.Buffer)
+	name := EncodeModifiedUTF8(className)
+	binary.Write(buff, binary.BigEndian, uint16(len(name)))
+	buff.Write(name)
+	binary.Write(buff, binary.BigEndian, uint32(0x0411))
+	sum := sha1.Sum(buff.Bytes())
+	var suid uint64
+	for i := 7; i >= 0; i-- {
+		suid = suid<<8 | uint64(sum[i])
+	}
+	return suid
+}
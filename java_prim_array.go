@@ -0,0 +1,288 @@
+package javaserializer
+
+import "fmt"
+import "io"
+import "math"
+import "reflect"
+import "unicode/utf16"
+import "encoding/binary"
+
+//基本类型数组不逐个装箱为interface{}, 而是整块读写到JavaTcArray.Prims:
+//[Z []bool, [B []byte, [C []uint16, [S []int16, [I []int32, [J []int64, [F []float32, [D []float64
+
+//PRIM_ARRAY_CHUNK the bytes read at a time from the stream for primitive array, long string & block data,
+//so that the corrupt length does not allocate the whole array before the data is there, see ReadNextBytes
+const PRIM_ARRAY_CHUNK = 1 << 16
+
+//MAX_PREALLOC_ELEMENTS the capacity preallocated at most for the elements of array, list & map,
+//the count is read from the stream, the slice grows by append as the elements are read
+const MAX_PREALLOC_ELEMENTS = 1024
+
+//readPrimArray read n elements of the primitive type eleType in bulk
+//byte[] is the sub slice of the data of DecodeBytes, the others are decoded into new slice
+func readPrimArray(eleType byte, n int, reader io.Reader) (interface{}, error) {
+	size := primSize(eleType)
+	if size == 0 {
+		return nil, fmt.Errorf("%w: Unexpected primitive type 0x%x", ErrUnsupportedClass, eleType)
+	}
+	bs, err := ReadNextBytes(reader, n*size)
+	if err != nil {
+		return nil, err
+	}
+	switch eleType {
+	case TC_PRIM_BYTE:
+		return bs, nil
+	case TC_PRIM_BOOLEAN:
+		vals := make([]bool, n)
+		for i, b := range bs {
+			vals[i] = b != 0
+		}
+		return vals, nil
+	case TC_PRIM_CHAR:
+		vals := make([]uint16, n)
+		for i := range vals {
+			vals[i] = binary.BigEndian.Uint16(bs[i*2:])
+		}
+		return vals, nil
+	case TC_PRIM_SHORT:
+		vals := make([]int16, n)
+		for i := range vals {
+			vals[i] = int16(binary.BigEndian.Uint16(bs[i*2:]))
+		}
+		return vals, nil
+	case TC_PRIM_INTEGER:
+		vals := make([]int32, n)
+		for i := range vals {
+			vals[i] = int32(binary.BigEndian.Uint32(bs[i*4:]))
+		}
+		return vals, nil
+	case TC_PRIM_LONG:
+		vals := make([]int64, n)
+		for i := range vals {
+			vals[i] = int64(binary.BigEndian.Uint64(bs[i*8:]))
+		}
+		return vals, nil
+	case TC_PRIM_FLOAT:
+		vals := make([]float32, n)
+		for i := range vals {
+			vals[i] = math.Float32frombits(binary.BigEndian.Uint32(bs[i*4:]))
+		}
+		return vals, nil
+	default: //TC_PRIM_DOUBLE
+		vals := make([]float64, n)
+		for i := range vals {
+			vals[i] = math.Float64frombits(binary.BigEndian.Uint64(bs[i*8:]))
+		}
+		return vals, nil
+	}
+}
+
+//encodePrimArray encode the typed slice prims in big endian
+//error if prims is not the slice type of eleType
+func encodePrimArray(eleType byte, prims interface{}) ([]byte, error) {
+	var bs []byte
+	switch vals := prims.(type) {
+	case []byte:
+		if eleType == TC_PRIM_BYTE {
+			return vals, nil
+		}
+	case []bool:
+		if eleType == TC_PRIM_BOOLEAN {
+			bs = make([]byte, len(vals))
+			for i, v := range vals {
+				if v {
+					bs[i] = 1
+				}
+			}
+			return bs, nil
+		}
+	case []uint16:
+		if eleType == TC_PRIM_CHAR {
+			bs = make([]byte, len(vals)*2)
+			for i, v := range vals {
+				binary.BigEndian.PutUint16(bs[i*2:], v)
+			}
+			return bs, nil
+		}
+	case []int16:
+		if eleType == TC_PRIM_SHORT {
+			bs = make([]byte, len(vals)*2)
+			for i, v := range vals {
+				binary.BigEndian.PutUint16(bs[i*2:], uint16(v))
+			}
+			return bs, nil
+		}
+	case []int32:
+		if eleType == TC_PRIM_INTEGER {
+			bs = make([]byte, len(vals)*4)
+			for i, v := range vals {
+				binary.BigEndian.PutUint32(bs[i*4:], uint32(v))
+			}
+			return bs, nil
+		}
+	case []int64:
+		if eleType == TC_PRIM_LONG {
+			bs = make([]byte, len(vals)*8)
+			for i, v := range vals {
+				binary.BigEndian.PutUint64(bs[i*8:], uint64(v))
+			}
+			return bs, nil
+		}
+	case []float32:
+		if eleType == TC_PRIM_FLOAT {
+			bs = make([]byte, len(vals)*4)
+			for i, v := range vals {
+				binary.BigEndian.PutUint32(bs[i*4:], math.Float32bits(v))
+			}
+			return bs, nil
+		}
+	case []float64:
+		if eleType == TC_PRIM_DOUBLE {
+			bs = make([]byte, len(vals)*8)
+			for i, v := range vals {
+				binary.BigEndian.PutUint64(bs[i*8:], math.Float64bits(v))
+			}
+			return bs, nil
+		}
+	}
+	return nil, fmt.Errorf("[JavaTcArray] %T is not the elements of primitive type %c", prims, eleType)
+}
+
+//primArrayTypes the typed slice of the primitive types
+var primArrayTypes = map[byte]reflect.Type{
+	TC_PRIM_BOOLEAN: reflect.TypeOf([]bool(nil)),
+	TC_PRIM_BYTE:    reflect.TypeOf([]byte(nil)),
+	TC_PRIM_CHAR:    reflect.TypeOf([]uint16(nil)),
+	TC_PRIM_SHORT:   reflect.TypeOf([]int16(nil)),
+	TC_PRIM_INTEGER: reflect.TypeOf([]int32(nil)),
+	TC_PRIM_LONG:    reflect.TypeOf([]int64(nil)),
+	TC_PRIM_FLOAT:   reflect.TypeOf([]float32(nil)),
+	TC_PRIM_DOUBLE:  reflect.TypeOf([]float64(nil)),
+}
+
+//primArrayType return the element type of primitive array className, e.g. TC_PRIM_INTEGER for [I
+func primArrayType(className string) (byte, bool) {
+	if len(className) != 2 || className[0] != '[' || !IsPrimType(className[1]) {
+		return 0, false
+	}
+	return className[1], true
+}
+
+//makePrimArray make the typed slice of n elements of the primitive type eleType
+func makePrimArray(eleType byte, n int) reflect.Value {
+	return reflect.MakeSlice(primArrayTypes[eleType], n, n)
+}
+
+//setPrimElement store v into the element i of the typed slice prims
+//the integer should fit the element either signed or unsigned, e.g. -1 & 255 for byte, no silent truncation
+func setPrimElement(prims reflect.Value, i int, v reflect.Value) error {
+	ev := prims.Index(i)
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return fmt.Errorf("[JavaTcArray] Null element of %s", prims.Type())
+	}
+	switch ev.Kind() {
+	case reflect.Bool:
+		if v.Kind() == reflect.Bool {
+			ev.SetBool(v.Bool())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			ev.SetFloat(v.Float())
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			ev.SetFloat(float64(v.Int()))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			ev.SetFloat(float64(v.Uint()))
+			return nil
+		}
+	default:
+		width := uint(ev.Type().Bits())
+		var bits uint64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i := v.Int()
+			if width < 64 && (i < -1<<(width-1) || i >= 1<<width) {
+				return fmt.Errorf("[JavaTcArray] %d overflows %s", i, ev.Type())
+			}
+			bits = uint64(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			bits = v.Uint()
+			if width < 64 && bits >= 1<<width {
+				return fmt.Errorf("[JavaTcArray] %d overflows %s", bits, ev.Type())
+			}
+		case reflect.String: //char of the field value
+			if units := utf16.Encode([]rune(v.String())); ev.Kind() == reflect.Uint16 && len(units) == 1 {
+				ev.SetUint(uint64(units[0]))
+				return nil
+			}
+			return fmt.Errorf("[JavaTcArray] %q is not one char", v.String())
+		default:
+			return fmt.Errorf("[JavaTcArray] %s is not the element of %s", v.Type(), prims.Type())
+		}
+		if ev.Kind() == reflect.Uint8 || ev.Kind() == reflect.Uint16 {
+			ev.SetUint(bits & (1<<width - 1))
+		} else {
+			ev.SetInt(int64(bits<<(64-width)) >> (64 - width))
+		}
+		return nil
+	}
+	return fmt.Errorf("[JavaTcArray] %s is not the element of %s", v.Type(), prims.Type())
+}
+
+//primArrayOf convert the boxed values to the typed slice of the primitive type eleType
+func primArrayOf(eleType byte, vals []interface{}) (interface{}, error) {
+	prims := makePrimArray(eleType, len(vals))
+	for i, val := range vals {
+		if err := setPrimElement(prims, i, reflect.ValueOf(val)); err != nil {
+			return nil, fmt.Errorf("%w, at [%d]", err, i)
+		}
+	}
+	return prims.Interface(), nil
+}
+
+//primArrayValues box the elements of typed slice prims as the field values, e.g. uint32 for int, rune for char
+//it keeps the json data of the primitive arrays the same as the boxed Values, e.g. numbers for byte[] rather than base64
+func primArrayValues(prims interface{}) []interface{} {
+	vals := make([]interface{}, 0, reflect.ValueOf(prims).Len())
+	switch x := prims.(type) {
+	case []byte:
+		for _, v := range x {
+			vals = append(vals, v)
+		}
+	case []bool:
+		for _, v := range x {
+			vals = append(vals, v)
+		}
+	case []uint16:
+		for _, v := range x {
+			vals = append(vals, rune(v))
+		}
+	case []int16:
+		for _, v := range x {
+			vals = append(vals, uint16(v))
+		}
+	case []int32:
+		for _, v := range x {
+			vals = append(vals, uint32(v))
+		}
+	case []int64:
+		for _, v := range x {
+			vals = append(vals, uint64(v))
+		}
+	case []float32:
+		for _, v := range x {
+			vals = append(vals, v)
+		}
+	case []float64:
+		for _, v := range x {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
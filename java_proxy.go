@@ -0,0 +1,110 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "encoding/binary"
+
+// newClassDesc:
+// 	TC_PROXYCLASSDESC newHandle proxyClassDescInfo
+// proxyClassDescInfo:
+// 	(int)<count> proxyInterfaceName[count] classAnnotation superClassDesc
+//
+// 动态代理对象(注解实例, RMI stub等)的classDesc链为 代理类 -> java.lang.reflect.Proxy,
+// 代理类本身没有field, java.lang.reflect.Proxy 只有一个field: InvocationHandler h
+
+const SID_PROXY uint64 = 0xE127DA20CC1043CB //java.lang.reflect.Proxy, -2222568056686623797L
+
+//DeserializeProxy read proxyClassDescInfo, TC_PROXYCLASSDESC has been consumed already
+//the super classDesc is not read here
+func (classDesc *JavaTcClassDesc) DeserializeProxy(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaTcClassDesc] Proxy >> ++ BEGIN\n")
+	defer refs.logger().Debug("[JavaTcClassDesc] Proxy << --END\n")
+	classDesc.IsProxy = true
+	classDesc.ScFlag = SC_SERIALIZABLE
+	classDesc.Fields = make([]*JavaField, 0)
+	//newHandle comes before proxyClassDescInfo
+	AddReference(refs, TC_CLASSDESC, classDesc)
+
+	count, err := ReadUint32(reader)
+	if err != nil {
+		return err
+	}
+	classDesc.ProxyInterfaces = make([]string, int(count))
+	for i := 0; i < int(count); i++ {
+		if nameLen, err := ReadUint16(reader); err != nil {
+			return err
+		} else if classDesc.ProxyInterfaces[i], err = readUTFString(reader, int(nameLen), refs.strict()); err != nil {
+			return err
+		}
+	}
+	refs.logger().Debug("[JavaTcClassDesc] Proxy interfaces %v\n", classDesc.ProxyInterfaces)
+	//classAnnotation
+	if b, err := ReadNextByte(reader); err != nil {
+		return err
+	} else if b != TC_ENDBLOCKDATA {
+		return typeCodeError(b, []byte{TC_ENDBLOCKDATA}, fmt.Errorf("[JavaTcClassDesc] Expect TC_ENDBLOCKDATA 0x78 after proxy interfaces, but got 0x%x", b))
+	}
+	return nil
+}
+
+//serializeProxy write TC_PROXYCLASSDESC newHandle proxyClassDescInfo without the super classDesc
+func (classDesc *JavaTcClassDesc) serializeProxy(writer io.Writer, refs *JavaReferencePool) error {
+	buff := make([]byte, 5)
+	var err error
+	buff[0] = TC_PROXYCLASSDESC
+	binary.BigEndian.PutUint32(buff[1:5], uint32(len(classDesc.ProxyInterfaces)))
+	if _, err = writer.Write(buff[:5]); err != nil {
+		return err
+	}
+	//newHandle
+	AddReference(refs, TC_CLASSDESC, classDesc)
+	for _, name := range classDesc.ProxyInterfaces {
+		nameArr, err := encodeUTFString(name, refs.strict())
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint16(buff[:2], uint16(len(nameArr)))
+		if _, err = writer.Write(buff[:2]); err != nil {
+			return err
+		}
+		if _, err = writer.Write(nameArr); err != nil {
+			return err
+		}
+	}
+	buff[0] = TC_ENDBLOCKDATA
+	_, err = writer.Write(buff[:1])
+	return err
+}
+
+//IsProxy judge if jo is a dynamic proxy object
+func (jo *JavaTcObject) IsProxy() bool {
+	return len(jo.Classes) > 0 && jo.Classes[0].IsProxy
+}
+
+//ProxyInterfaces return the interfaces implemented by the proxy object, nil if it's not a proxy
+func (jo *JavaTcObject) ProxyInterfaces() []string {
+	if !jo.IsProxy() {
+		return nil
+	}
+	return jo.Classes[0].ProxyInterfaces
+}
+
+//InvocationHandler return the value of java.lang.reflect.Proxy.h, nil if it's not a proxy
+func (jo *JavaTcObject) InvocationHandler() interface{} {
+	if !jo.IsProxy() {
+		return nil
+	}
+	for _, cc := range jo.Classes {
+		if cc.ClassName != "java.lang.reflect.Proxy" {
+			continue
+		}
+		for _, jf := range cc.Fields {
+			if jf.FieldName == "h" {
+				return jf.FieldValue
+			}
+		}
+	}
+	return nil
+}
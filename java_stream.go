@@ -0,0 +1,246 @@
+package javaserializer
+
+import "io"
+import "fmt"
+import "encoding/binary"
+
+// stream:
+// 	magic version contents
+// contents:
+// 	content
+// 	contents content
+// content:
+// 	object
+// 	blockdata
+//
+// TC_RESET 只会出现在content之间, 出现后handle重新从 INTBASE_WIRE_HANDLE 开始编号
+
+//ReadNextContentTypeCode read next content's type code, TC_RESET before it will be consumed and the refs will be reset
+func ReadNextContentTypeCode(reader io.Reader, refs *JavaReferencePool) (byte, error) {
+	for {
+		if b, err := ReadNextByte(reader); err != nil {
+			return 0, err
+		} else if b != TC_RESET {
+			return b, nil
+		}
+		ResetReference(refs)
+	}
+}
+
+//JavaContent one top level content of the stream
+type JavaContent struct {
+	Kind   byte           //TC_OBJECT, TC_ARRAY, TC_STRING, TC_ENUM, TC_CLASS, TC_NULL or TC_BLOCKDATA; TC_LONGSTRING 归为 TC_STRING, TC_BLOCKDATALONG 归为 TC_BLOCKDATA
+	Entity JavaSerializer //nil for TC_NULL & TC_BLOCKDATA
+	Data   []byte         //block data for TC_BLOCKDATA
+}
+
+//JavaStreamReader read java contents from one stream one by one, aka ObjectInputStream
+//handles are shared between the contents until TC_RESET
+type JavaStreamReader struct {
+	reader     *CountingReader
+	refs       *JavaReferencePool
+	block      *JavaBlockDataReader
+	headerRead bool
+}
+
+//NewJavaStreamReader new java stream reader, STREAM_MAGIC & STREAM_VERSION will be read before the first content
+func NewJavaStreamReader(reader io.Reader) *JavaStreamReader {
+	return newJavaStreamReader(NewCountingReader(reader))
+}
+
+func newJavaStreamReader(reader *CountingReader) *JavaStreamReader {
+	sr := &JavaStreamReader{
+		reader: reader,
+		refs:   NewJavaReferencePool(1 << 10),
+	}
+	sr.refs.context().in = sr.reader
+	sr.block = NewJavaBlockDataReader(sr.reader)
+	sr.block.refs = sr.refs
+	return sr
+}
+
+//BlockData return the DataInput like reader of the top level block data, aka ObjectInputStream.readInt etc.
+//the reader returns io.EOF when the next content is not block data, then ReadContent can go on
+func (sr *JavaStreamReader) BlockData() (*JavaBlockDataReader, error) {
+	if err := sr.readHeader(); err != nil {
+		return nil, err
+	}
+	return sr.block, nil
+}
+
+//readHeader read STREAM_MAGIC & STREAM_VERSION once
+//io.EOF for the empty stream, other errors are *DecodeError
+func (sr *JavaStreamReader) readHeader() error {
+	if sr.headerRead {
+		return nil
+	}
+	if err := ReadStreamHeader(sr.reader); err == io.EOF && sr.reader.Offset == 0 {
+		return err
+	} else if err != nil {
+		return sr.refs.decodeError(err)
+	}
+	sr.headerRead = true
+	return nil
+}
+
+//ReadStreamHeader read and check STREAM_MAGIC & STREAM_VERSION
+func ReadStreamHeader(reader io.Reader) error {
+	//read magic
+	if b, err := ReadUint16(reader); err != nil {
+		return err
+	} else if b != uint16(STREAM_MAGIC) {
+		return fmt.Errorf("stream should start with STREAM_MAGIC but got 0x%x", b)
+	}
+	//read version
+	if b, err := ReadUint16(reader); err != nil {
+		return err
+	} else if b != uint16(STREAM_VERSION) {
+		return fmt.Errorf("stream should start with STREAM_VERSION but got 0x%x", b)
+	}
+	return nil
+}
+
+//ReadContent read next content of the stream, io.EOF if there is no more content
+//*JavaException is returned for TC_EXCEPTION, the contents after it can still be read
+//other errors are *DecodeError, including the errors of the stream header
+func (sr *JavaStreamReader) ReadContent() (*JavaContent, error) {
+	if err := sr.readHeader(); err != nil {
+		return nil, err
+	}
+	//BlockData 未读完的部分
+	if sr.block.remain > 0 {
+		content := &JavaContent{
+			Kind: TC_BLOCKDATA,
+			Data: make([]byte, sr.block.remain),
+		}
+		if err := sr.block.ReadFully(content.Data); err != nil {
+			return nil, sr.refs.decodeError(err)
+		}
+		return content, nil
+	}
+	//content 之间的 io.EOF 表示流正常结束
+	b, err := ReadNextContentTypeCode(sr.reader, sr.refs)
+	if err == io.EOF {
+		return nil, err
+	} else if err != nil {
+		return nil, sr.refs.decodeError(err)
+	}
+	content, err := sr.readContent(b)
+	if err != nil {
+		sr.refs.context().path = nil
+		return nil, sr.refs.decodeError(err)
+	}
+	return content, nil
+}
+
+//readContent read the content whose type code b has been consumed already
+func (sr *JavaStreamReader) readContent(b byte) (*JavaContent, error) {
+	var err error
+	content := &JavaContent{
+		Kind: b,
+	}
+	switch b {
+	case TC_NULL:
+		return content, nil
+	case TC_BLOCKDATA, TC_BLOCKDATALONG:
+		content.Kind = TC_BLOCKDATA
+		if content.Data, err = ReadBlockDataContent(b, sr.reader); err != nil {
+			return nil, err
+		}
+		return content, nil
+	case TC_LONGSTRING:
+		content.Kind = TC_STRING
+	case TC_REFERENCE:
+		//再次写入的对象, 其Kind为被引用对象的类型
+		if refIndex, err := ReadUint32(sr.reader); err != nil {
+			return nil, err
+		} else if ref, err := sr.refs.Get(refIndex); err != nil {
+			return nil, err
+		} else {
+			content.Kind = ref.RefType
+			if str, ok := ref.Val.(string); ok {
+				content.Entity = NewJavaTcString(str)
+			} else if js, ok := ref.Val.(JavaSerializer); ok {
+				content.Entity = js
+			} else {
+				return nil, fmt.Errorf("%w: Unexpected reference 0x%x, %v", ErrBadReference, refIndex, ref.Val)
+			}
+			return content, nil
+		}
+	}
+	if content.Entity, err = ReadEleWithTypeCode(b, sr.reader, sr.refs); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+//JavaStreamWriter write java entities to one stream one by one, aka ObjectOutputStream
+//handles are shared between the entities until Reset
+type JavaStreamWriter struct {
+	writer        io.Writer
+	refs          *JavaReferencePool
+	block         *JavaBlockDataWriter
+	headerWritten bool
+}
+
+//NewJavaStreamWriter new java stream writer, STREAM_MAGIC & STREAM_VERSION will be written before the first content
+func NewJavaStreamWriter(writer io.Writer) *JavaStreamWriter {
+	return &JavaStreamWriter{
+		writer: writer,
+		refs:   NewJavaReferencePool(1 << 10),
+		block:  NewJavaBlockDataWriter(writer),
+	}
+}
+
+//BlockData return the DataOutput like writer of the top level block data, aka ObjectOutputStream.writeInt etc.
+//the data is buffered until WriteEntity, Reset or Flush
+func (sw *JavaStreamWriter) BlockData() (*JavaBlockDataWriter, error) {
+	if err := sw.writeHeader(); err != nil {
+		return nil, err
+	}
+	return sw.block, nil
+}
+
+//Flush write the buffered block data, aka ObjectOutputStream.flush()
+func (sw *JavaStreamWriter) Flush() error {
+	if err := sw.writeHeader(); err != nil {
+		return err
+	}
+	return sw.block.Flush()
+}
+
+//writeHeader write STREAM_MAGIC & STREAM_VERSION once
+func (sw *JavaStreamWriter) writeHeader() error {
+	if sw.headerWritten {
+		return nil
+	}
+	buff := make([]byte, 4)
+	binary.BigEndian.PutUint16(buff[:2], STREAM_MAGIC)
+	binary.BigEndian.PutUint16(buff[2:4], STREAM_VERSION)
+	if _, err := sw.writer.Write(buff); err != nil {
+		return err
+	}
+	sw.headerWritten = true
+	return nil
+}
+
+//WriteEntity write java entity as the next content of the stream
+func (sw *JavaStreamWriter) WriteEntity(entity JavaSerializer) error {
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	return entity.Serialize(sw.writer, sw.refs)
+}
+
+//Reset write TC_RESET, entities written before will not be referenced any more
+//aka ObjectOutputStream.reset()
+func (sw *JavaStreamWriter) Reset() error {
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	if _, err := sw.writer.Write([]byte{TC_RESET}); err != nil {
+		return err
+	}
+	ResetReference(sw.refs)
+	return nil
+}
@@ -0,0 +1,431 @@
+package javaserializer
+
+import "io"
+import "fmt"
+
+//JavaTokenReader 以事件流的方式读取java序列化流, 不生成对象图, 适合读取很大的流
+//对象及数组只保留handle, 不保留其内容; classDesc 与字符串仍然保存在handle表中, enum及后续的classDesc需要引用它们
+//
+//一个对象的token为:
+// 	BEGIN_OBJECT
+// 	父类在前, 每个类的 FIELD ...
+// 	有writeObject的类在其field之后为 ANNOTATION, 之后为 BLOCK_DATA 或对象等token, 以 END 结束
+// 	END
+//基本类型的FIELD 带有其值, 对象类型的FIELD 之后的下一个token为其值, 如 STRING, NULL, REFERENCE, BEGIN_OBJECT
+//数组为 BEGIN_ARRAY, 基本类型的元素为 VALUE, 其他元素为对象的token, 最后为 END
+//Externalizable 对象为 BEGIN_OBJECT ANNOTATION ... END END
+
+//JavaTokenKind kind of JavaToken
+type JavaTokenKind byte
+
+const (
+	TOKEN_BEGIN_OBJECT JavaTokenKind = iota + 1 //ClassDesc, Handle
+	TOKEN_BEGIN_ARRAY                           //ClassDesc, Handle, Len
+	TOKEN_FIELD                                 //Name, Value for prim field; the value of object field is the next token
+	TOKEN_VALUE                                 //Value, prim element of array
+	TOKEN_STRING                                //Value string, Handle
+	TOKEN_NULL                                  //TC_NULL
+	TOKEN_REFERENCE                             //Handle referenced
+	TOKEN_ENUM                                  //ClassDesc, Handle, Value constant name
+	TOKEN_CLASS                                 //ClassDesc, Handle
+	TOKEN_ANNOTATION                            //ClassDesc, the data written by writeObject or writeExternal follows till END
+	TOKEN_BLOCK_DATA                            //Data
+	TOKEN_END                                   //end of object, array or annotation
+	TOKEN_RESET                                 //TC_RESET between the contents
+)
+
+var tokenKindNames = map[JavaTokenKind]string{
+	TOKEN_BEGIN_OBJECT: "BEGIN_OBJECT",
+	TOKEN_BEGIN_ARRAY:  "BEGIN_ARRAY",
+	TOKEN_FIELD:        "FIELD",
+	TOKEN_VALUE:        "VALUE",
+	TOKEN_STRING:       "STRING",
+	TOKEN_NULL:         "NULL",
+	TOKEN_REFERENCE:    "REFERENCE",
+	TOKEN_ENUM:         "ENUM",
+	TOKEN_CLASS:        "CLASS",
+	TOKEN_ANNOTATION:   "ANNOTATION",
+	TOKEN_BLOCK_DATA:   "BLOCK_DATA",
+	TOKEN_END:          "END",
+	TOKEN_RESET:        "RESET",
+}
+
+func (kind JavaTokenKind) String() string {
+	if name, ok := tokenKindNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("JavaTokenKind(%d)", byte(kind))
+}
+
+//JavaToken one event of the stream
+type JavaToken struct {
+	Kind      JavaTokenKind
+	ClassDesc *JavaTcClassDesc //class of BEGIN_OBJECT, BEGIN_ARRAY, ENUM, CLASS; the class whose writeObject data follows ANNOTATION
+	Handle    uint32           //newHandle of BEGIN_OBJECT, BEGIN_ARRAY, STRING, ENUM, CLASS; the handle referenced by REFERENCE
+	Name      string           //field name of FIELD
+	Value     interface{}      //prim value of FIELD & VALUE, the same as JavaField.FieldValue; string of STRING & ENUM
+	Len       int              //length of BEGIN_ARRAY
+	Data      []byte           //BLOCK_DATA
+}
+
+//tokenFrame object, array or annotation not ended yet
+type tokenFrame struct {
+	kind     JavaTokenKind      //TOKEN_BEGIN_OBJECT, TOKEN_BEGIN_ARRAY or TOKEN_ANNOTATION
+	classes  []*JavaTcClassDesc //object: 子类在前, 父类在后
+	class    int                //object: index of the class being read, from the super class
+	field    int                //object: index of the next field
+	elemType byte               //array: element type code
+	elemSize int                //array: bytes of prim element, 0 for object element
+	remain   int64              //array: elements not read yet
+}
+
+//JavaTokenReader pull style reader of the stream, see Next
+type JavaTokenReader struct {
+	reader     *CountingReader
+	refs       *JavaReferencePool
+	stack      []*tokenFrame
+	pending    bool //the value of object field is the next token
+	headerRead bool
+}
+
+//NewJavaTokenReader new token reader, STREAM_MAGIC & STREAM_VERSION will be read before the first token
+func NewJavaTokenReader(reader io.Reader) *JavaTokenReader {
+	return &JavaTokenReader{
+		reader: NewCountingReader(reader),
+		refs:   NewJavaReferencePool(1 << 10),
+	}
+}
+
+//Offset return the bytes have been read from the stream
+func (tr *JavaTokenReader) Offset() int64 {
+	return tr.reader.Offset
+}
+
+//Depth return count of the objects, arrays and annotations not ended yet
+func (tr *JavaTokenReader) Depth() int {
+	return len(tr.stack)
+}
+
+//Next read next token, io.EOF if there is no more content
+func (tr *JavaTokenReader) Next() (JavaToken, error) {
+	if !tr.headerRead {
+		if err := ReadStreamHeader(tr.reader); err != nil {
+			return JavaToken{}, err
+		}
+		tr.headerRead = true
+	}
+	if tr.pending {
+		tr.pending = false
+		b, err := ReadNextByte(tr.reader)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		return tr.value(b)
+	}
+	if len(tr.stack) == 0 {
+		return tr.content()
+	}
+	frame := tr.stack[len(tr.stack)-1]
+	switch frame.kind {
+	case TOKEN_BEGIN_ARRAY:
+		return tr.element(frame)
+	case TOKEN_ANNOTATION:
+		return tr.annotation()
+	}
+	return tr.field(frame)
+}
+
+//content read next top level content
+func (tr *JavaTokenReader) content() (JavaToken, error) {
+	b, err := ReadNextByte(tr.reader)
+	if err != nil {
+		return JavaToken{}, err
+	}
+	switch b {
+	case TC_RESET:
+		ResetReference(tr.refs)
+		return JavaToken{Kind: TOKEN_RESET}, nil
+	case TC_BLOCKDATA, TC_BLOCKDATALONG:
+		data, err := ReadBlockDataContent(b, tr.reader)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		return JavaToken{Kind: TOKEN_BLOCK_DATA, Data: data}, nil
+	}
+	return tr.value(b)
+}
+
+//field read next field of the object, or END of the object
+func (tr *JavaTokenReader) field(frame *tokenFrame) (JavaToken, error) {
+	//Externalizable 只有最终子类的externalContents
+	if frame.class >= 0 && frame.classes[0].IsExternalizable() {
+		frame.class = -1
+		tr.stack = append(tr.stack, &tokenFrame{kind: TOKEN_ANNOTATION})
+		return JavaToken{Kind: TOKEN_ANNOTATION, ClassDesc: frame.classes[0]}, nil
+	}
+	for frame.class >= 0 {
+		cc := frame.classes[frame.class]
+		if cc.ScFlag&SC_SERIALIZABLE == 0 {
+			//没有classdata的类
+			frame.class--
+			continue
+		}
+		if frame.field < len(cc.Fields) {
+			jf := cc.Fields[frame.field]
+			frame.field++
+			tok := JavaToken{Kind: TOKEN_FIELD, Name: jf.FieldName}
+			if !IsPrimType(jf.FieldType) {
+				tr.pending = true
+				return tok, nil
+			}
+			v, err := ReadTcPrimFieldValue(jf.FieldType, tr.reader)
+			if err != nil {
+				return JavaToken{}, unexpectedEOF(err)
+			}
+			tok.Value = v
+			return tok, nil
+		}
+		frame.class--
+		frame.field = 0
+		if cc.HasWriteMethod() {
+			tr.stack = append(tr.stack, &tokenFrame{kind: TOKEN_ANNOTATION})
+			return JavaToken{Kind: TOKEN_ANNOTATION, ClassDesc: cc}, nil
+		}
+	}
+	tr.stack = tr.stack[:len(tr.stack)-1]
+	return JavaToken{Kind: TOKEN_END}, nil
+}
+
+//annotation read next block data or object of the annotation, END for TC_ENDBLOCKDATA
+func (tr *JavaTokenReader) annotation() (JavaToken, error) {
+	b, err := ReadNextByte(tr.reader)
+	if err != nil {
+		return JavaToken{}, unexpectedEOF(err)
+	}
+	switch b {
+	case TC_ENDBLOCKDATA:
+		tr.stack = tr.stack[:len(tr.stack)-1]
+		return JavaToken{Kind: TOKEN_END}, nil
+	case TC_BLOCKDATA, TC_BLOCKDATALONG:
+		data, err := ReadBlockDataContent(b, tr.reader)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		return JavaToken{Kind: TOKEN_BLOCK_DATA, Data: data}, nil
+	}
+	return tr.value(b)
+}
+
+//element read next element of the array, or END of the array
+func (tr *JavaTokenReader) element(frame *tokenFrame) (JavaToken, error) {
+	if frame.remain == 0 {
+		tr.stack = tr.stack[:len(tr.stack)-1]
+		return JavaToken{Kind: TOKEN_END}, nil
+	}
+	frame.remain--
+	if frame.elemSize > 0 {
+		v, err := ReadTcPrimFieldValue(frame.elemType, tr.reader)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		return JavaToken{Kind: TOKEN_VALUE, Value: v}, nil
+	}
+	b, err := ReadNextByte(tr.reader)
+	if err != nil {
+		return JavaToken{}, unexpectedEOF(err)
+	}
+	return tr.value(b)
+}
+
+//value read the object, array, string etc. whose type code b has been consumed already
+func (tr *JavaTokenReader) value(b byte) (JavaToken, error) {
+	switch b {
+	case TC_NULL:
+		return JavaToken{Kind: TOKEN_NULL}, nil
+	case TC_REFERENCE:
+		handle, err := ReadUint32(tr.reader)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		if _, err = tr.refs.Get(handle); err != nil {
+			return JavaToken{}, err
+		}
+		return JavaToken{Kind: TOKEN_REFERENCE, Handle: handle}, nil
+	case TC_STRING, TC_LONGSTRING:
+		str, err := ReadTcStringContent(b, tr.reader, tr.refs)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		return JavaToken{Kind: TOKEN_STRING, Value: str, Handle: tr.lastHandle()}, nil
+	case TC_OBJECT, TC_ARRAY, TC_ENUM, TC_CLASS:
+	default:
+		return JavaToken{}, fmt.Errorf("[JavaTokenReader] Unexpected type code 0x%x at offset %d", b, tr.reader.Offset-1)
+	}
+
+	first, err := ReadNextByte(tr.reader)
+	if err != nil {
+		return JavaToken{}, unexpectedEOF(err)
+	}
+	classes, err := ReadClassDescChain(first, tr.reader, tr.refs)
+	if err != nil {
+		return JavaToken{}, err
+	} else if len(classes) == 0 {
+		return JavaToken{}, fmt.Errorf("[JavaTokenReader] Expected TC_CLASSDESC, but got TC_NULL")
+	}
+	tok := JavaToken{ClassDesc: classes[0]}
+	switch b {
+	case TC_CLASS:
+		tok.Kind = TOKEN_CLASS
+		tok.Handle = tr.refs.Add(TC_CLASS, nil)
+	case TC_ENUM:
+		tok.Kind = TOKEN_ENUM
+		tok.Handle = tr.refs.Add(TC_ENUM, nil)
+		if tok.Value, err = tr.enumConstant(); err != nil {
+			return JavaToken{}, err
+		}
+	case TC_ARRAY:
+		tok.Kind = TOKEN_BEGIN_ARRAY
+		tok.Handle = tr.refs.Add(TC_ARRAY, nil)
+		name := tok.ClassDesc.ClassName
+		if len(name) < 2 || name[0] != '[' {
+			return JavaToken{}, fmt.Errorf("[JavaTokenReader] Illegal array class name %s", name)
+		}
+		size, err := ReadUint32(tr.reader)
+		if err != nil {
+			return JavaToken{}, unexpectedEOF(err)
+		}
+		if int32(size) < 0 {
+			return JavaToken{}, fmt.Errorf("[JavaTokenReader] Illegal array size %d", int32(size))
+		}
+		tok.Len = int(size)
+		tr.stack = append(tr.stack, &tokenFrame{
+			kind:     TOKEN_BEGIN_ARRAY,
+			elemType: name[1],
+			elemSize: primSize(name[1]),
+			remain:   int64(size),
+		})
+	case TC_OBJECT:
+		tok.Kind = TOKEN_BEGIN_OBJECT
+		tok.Handle = tr.refs.Add(TC_OBJECT, nil)
+		frame := &tokenFrame{
+			kind:    TOKEN_BEGIN_OBJECT,
+			classes: classes,
+			class:   len(classes) - 1,
+		}
+		if classes[0].IsExternalizable() && classes[0].ScFlag&SC_BLOCK_DATA == 0 {
+			return JavaToken{}, fmt.Errorf("%w: [JavaTokenReader] %s is written by PROTOCOL_VERSION_1, it cannot be read as tokens", ErrUnsupportedClass, classes[0].ClassName)
+		}
+		tr.stack = append(tr.stack, frame)
+	}
+	return tok, nil
+}
+
+//enumConstant read the constant name of enum, TC_STRING or TC_REFERENCE to it
+func (tr *JavaTokenReader) enumConstant() (string, error) {
+	b, err := ReadNextByte(tr.reader)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if b != TC_REFERENCE {
+		return ReadTcStringContent(b, tr.reader, tr.refs)
+	}
+	handle, err := ReadUint32(tr.reader)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	ref, err := tr.refs.Get(handle)
+	if err != nil {
+		return "", err
+	}
+	if str, ok := ref.Val.(string); ok {
+		return str, nil
+	}
+	return "", fmt.Errorf("%w: [JavaTokenReader] Expected enum constant name @ ref [0x%x], but got 0x%x", ErrBadReference, handle, ref.RefType)
+}
+
+func (tr *JavaTokenReader) lastHandle() uint32 {
+	return tr.refs.next - 1
+}
+
+//Skip skip the value of object field just returned by FIELD, or the rest of the innermost object, array or annotation
+//including its END, e.g. Skip right after BEGIN_OBJECT skips the whole object
+//the prim elements of array are discarded without being decoded
+func (tr *JavaTokenReader) Skip() error {
+	if tr.pending {
+		tok, err := tr.Next()
+		if err != nil {
+			return err
+		}
+		switch tok.Kind {
+		case TOKEN_BEGIN_OBJECT, TOKEN_BEGIN_ARRAY:
+			return tr.Skip()
+		}
+		return nil
+	}
+	depth := len(tr.stack)
+	for len(tr.stack) >= depth && depth > 0 {
+		frame := tr.stack[len(tr.stack)-1]
+		if frame.kind == TOKEN_BEGIN_ARRAY && frame.elemSize > 0 && frame.remain > 0 {
+			if _, err := io.CopyN(io.Discard, tr.reader, frame.remain*int64(frame.elemSize)); err != nil {
+				return unexpectedEOF(err)
+			}
+			frame.remain = 0
+		}
+		if _, err := tr.Next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ReadArray read the raw big-endian bytes of the prim elements of the innermost array, as many whole elements as p can hold
+//io.EOF is returned when all the elements have been read, then Next returns END of the array
+//it can be mixed with VALUE tokens, so huge arrays can be streamed in chunks
+func (tr *JavaTokenReader) ReadArray(p []byte) (int, error) {
+	if len(tr.stack) == 0 || tr.pending {
+		return 0, fmt.Errorf("[JavaTokenReader] ReadArray is not inside array")
+	}
+	frame := tr.stack[len(tr.stack)-1]
+	if frame.kind != TOKEN_BEGIN_ARRAY || frame.elemSize == 0 {
+		return 0, fmt.Errorf("[JavaTokenReader] ReadArray is not inside prim array")
+	}
+	if frame.remain == 0 {
+		return 0, io.EOF
+	}
+	n := int64(len(p) / frame.elemSize)
+	if n == 0 {
+		return 0, io.ErrShortBuffer
+	}
+	if n > frame.remain {
+		n = frame.remain
+	}
+	read, err := io.ReadFull(tr.reader, p[:n*int64(frame.elemSize)])
+	frame.remain -= int64(read / frame.elemSize)
+	if err != nil {
+		return read, unexpectedEOF(err)
+	}
+	return read, nil
+}
+
+//primSize bytes of the prim type, 0 for object types
+func primSize(tc byte) int {
+	switch tc {
+	case TC_PRIM_BYTE, TC_PRIM_BOOLEAN:
+		return 1
+	case TC_PRIM_CHAR, TC_PRIM_SHORT:
+		return 2
+	case TC_PRIM_INTEGER, TC_PRIM_FLOAT:
+		return 4
+	case TC_PRIM_LONG, TC_PRIM_DOUBLE:
+		return 8
+	}
+	return 0
+}
+
+//unexpectedEOF io.EOF inside content means the stream is truncated
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return fmt.Errorf("%w: %w", ErrTruncated, io.ErrUnexpectedEOF)
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package javaserializer
+
+import "fmt"
+
+// JsonMap 按需从对象图生成json风格的数据, 不再在反序列化时预先计算
+// 同一个java对象的多次引用得到的是同一个 *JavaTcObject/*JavaTcArray, 对象图中可能存在环,
+// 例如 Throwable.cause 指向自己, 双向链表的 prev/next
+// 当某个对象出现在其自身的数据中时, 输出 {"__ref__": path} 代替, path 为该对象所在的路径, 例如 $.parent
+
+//JSON_REF_KEY key of the marker which replaces a cyclic reference in JsonMap
+const JSON_REF_KEY = "__ref__"
+
+//jsonMapper is implemented by the values which may contain other objects
+type jsonMapper interface {
+	jsonMap(v *jsonVisitor, path string) interface{}
+}
+
+//jsonVisitor remember the objects being converted, aka the ancestors of current value
+type jsonVisitor struct {
+	paths map[interface{}]string
+}
+
+func newJsonVisitor() *jsonVisitor {
+	return &jsonVisitor{
+		paths: make(map[interface{}]string),
+	}
+}
+
+//enter mark obj as being converted, return the reference marker and false if it is an ancestor already
+func (v *jsonVisitor) enter(obj interface{}, path string) (interface{}, bool) {
+	if p, found := v.paths[obj]; found {
+		return map[string]interface{}{JSON_REF_KEY: p}, false
+	}
+	v.paths[obj] = path
+	return nil, true
+}
+
+//leave obj is converted, it may appear again in its siblings
+func (v *jsonVisitor) leave(obj interface{}) {
+	delete(v.paths, obj)
+}
+
+//value return json style data of val located at path
+func (v *jsonVisitor) value(val interface{}, path string) interface{} {
+	switch x := val.(type) {
+	case jsonMapper:
+		return x.jsonMap(v, path)
+	case JavaSerializer:
+		return x.JsonMap()
+	default:
+		return val
+	}
+}
+
+//values return json style data of the slice located at path
+func (v *jsonVisitor) values(vals []interface{}, path string) []interface{} {
+	datas := make([]interface{}, len(vals))
+	for i, val := range vals {
+		datas[i] = v.value(val, fmt.Sprintf("%s[%d]", path, i))
+	}
+	return datas
+}
+
+//IsJsonRef judge if the json data is the marker of a cyclic reference, return the path of the referenced object
+func IsJsonRef(data interface{}) (string, bool) {
+	mp, ok := data.(map[string]interface{})
+	if !ok || len(mp) != 1 {
+		return "", false
+	}
+	p, ok := mp[JSON_REF_KEY].(string)
+	return p, ok
+}
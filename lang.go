@@ -1,26 +1,34 @@
-package main
+package javaserializer
 
 import "io"
+import "bufio"
 import "encoding/binary"
 import "fmt"
+import "math"
+import "reflect"
+import "slices"
+import "strings"
 
 //定义基础类型
 //author: davidwang2006@aliyun.com
 //date: 2018-01-29 15:32:15
 
 const (
-	TC_NULL          byte = 0x70 | iota //0x70
-	TC_REFERENCE     byte = 0x70 | iota //0x71
-	TC_CLASSDESC     byte = 0x70 | iota //0x72
-	TC_OBJECT        byte = 0x70 | iota //0x73
-	TC_STRING        byte = 0x70 | iota //0x74
-	TC_ARRAY         byte = 0x70 | iota //0x75
-	TC_CLASS         byte = 0x70 | iota //0x76
-	TC_BLOCKDATA     byte = 0x70 | iota //0x77
-	TC_ENDBLOCKDATA  byte = 0x70 | iota //0x78
-	TC_RESET         byte = 0x70 | iota //0x79
-	TC_BLOCKDATALONG byte = 0x70 | iota //0x7A
-	TC_EXCEPTION     byte = 0x70 | iota //0x7B
+	TC_NULL           byte = 0x70 | iota //0x70
+	TC_REFERENCE      byte = 0x70 | iota //0x71
+	TC_CLASSDESC      byte = 0x70 | iota //0x72
+	TC_OBJECT         byte = 0x70 | iota //0x73
+	TC_STRING         byte = 0x70 | iota //0x74
+	TC_ARRAY          byte = 0x70 | iota //0x75
+	TC_CLASS          byte = 0x70 | iota //0x76
+	TC_BLOCKDATA      byte = 0x70 | iota //0x77
+	TC_ENDBLOCKDATA   byte = 0x70 | iota //0x78
+	TC_RESET          byte = 0x70 | iota //0x79
+	TC_BLOCKDATALONG  byte = 0x70 | iota //0x7A
+	TC_EXCEPTION      byte = 0x70 | iota //0x7B
+	TC_LONGSTRING     byte = 0x70 | iota //0x7C
+	TC_PROXYCLASSDESC byte = 0x70 | iota //0x7D
+	TC_ENUM           byte = 0x70 | iota //0x7E
 )
 
 //type code define
@@ -62,33 +70,39 @@ const SC_WRITE_METHOD byte = 0x01
 const SC_SERIALIZABLE byte = 0x02 //only support this one
 const SC_RW_OBJECT byte = 0x03    //拥有自己的writeObject, readObject, for example: HashMap, 此种类型需要每一个定义一个相应的结构体
 const SC_EXTERNALIZABLE byte = 0x04
+const SC_BLOCK_DATA byte = 0x08 //与SC_EXTERNALIZABLE一同出现即0x0C, 表示writeExternal的数据以block data写入(protocol version 2)
+const SC_ENUM byte = 0x10       //enum class desc, 与SC_SERIALIZABLE一同出现即0x12
 
 //define some serialiable objects' serialVersionUID
 const (
-	SID_STRING_ARRAY uint64 = 0xADD256E7E91D7B47
-	SID_BYTE_ARRAY   uint64 = 0xACF317F8060854E0
-	SID_INT_ARRAY    uint64 = 0x4DBA602676EAB2A5
-	SID_SHORT_ARRAY  uint64 = 0xEF832E06E55DB0FA
-	SID_LONG_ARRAY   uint64 = 0x782004B512B17593
-	SID_INTEGER      uint64 = 1360826667806852920 //decimal
-	SID_LONG         uint64 = 4290774380558885855 //decimal
-	SID_SHORT        uint64 = 7515723908773894738 //decimal
-	SID_BYTE         uint64 = 0x9C4E6084EE50F51C
-	SID_FLOAT        uint64 = 0xDAEDC9A2DB3CF0EC
-	SID_DOUBLE       uint64 = 0x80B3C24A296BFB04
-	SID_BOOLEAN      uint64 = 0xCD207280D59CFAEE
-	SID_CHARACTER    uint64 = 3786198910865385080 //decimal
+	SID_STRING_ARRAY  uint64 = 0xADD256E7E91D7B47
+	SID_BYTE_ARRAY    uint64 = 0xACF317F8060854E0
+	SID_INT_ARRAY     uint64 = 0x4DBA602676EAB2A5
+	SID_SHORT_ARRAY   uint64 = 0xEF832E06E55DB0FA
+	SID_LONG_ARRAY    uint64 = 0x782004B512B17593
+	SID_BOOLEAN_ARRAY uint64 = 0x578F203914B85DE2
+	SID_CHAR_ARRAY    uint64 = 0xB02666B0E25D84AC
+	SID_FLOAT_ARRAY   uint64 = 0x0B9C818922E00C42
+	SID_DOUBLE_ARRAY  uint64 = 0x3EA68C14AB635A1E
+	SID_INTEGER       uint64 = 1360826667806852920 //decimal
+	SID_LONG          uint64 = 4290774380558885855 //decimal
+	SID_SHORT         uint64 = 7515723908773894738 //decimal
+	SID_BYTE          uint64 = 0x9C4E6084EE50F51C
+	SID_FLOAT         uint64 = 0xDAEDC9A2DB3CF0EC
+	SID_DOUBLE        uint64 = 0x80B3C24A296BFB04
+	SID_BOOLEAN       uint64 = 0xCD207280D59CFAEE
+	SID_CHARACTER     uint64 = 3786198910865385080 //decimal
 )
 
 //JavaReferenceObject java reference object
 type JavaReferenceObject struct {
-	RefType byte        //引用类型，TC_OBJECT, TC_CLASSDESC, TC_ARRAY, TC_STRING 共4种
+	RefType byte        //引用类型，TC_OBJECT, TC_CLASSDESC, TC_ARRAY, TC_STRING, TC_ENUM, TC_CLASS
 	Val     interface{} //引用的值
 }
 
 type JavaSerializer interface {
-	Serialize(io.Writer, []*JavaReferenceObject) error
-	Deserialize(io.Reader, []*JavaReferenceObject) error
+	Serialize(io.Writer, *JavaReferencePool) error
+	Deserialize(io.Reader, *JavaReferencePool) error
 	JsonMap() interface{}
 }
 
@@ -102,18 +116,138 @@ func IsPrimType(typ byte) bool {
 	}
 }
 
+//JavaReferencePool handle table of one stream, to hold the TC_REF Object
+//handles are assigned from INTBASE_WIRE_HANDLE in protocol order, it grows without limit
+type JavaReferencePool struct {
+	refs  map[uint32]*JavaReferenceObject //handle -> reference
+	index map[interface{}]uint32          //reference key -> handle, see referenceKey
+	next  uint32                          //next newHandle
+	ctx   *codecContext                   //options & state of the Decoder or Encoder which owns the pool
+}
+
+//reference keys of the values which are singletons in java, the same key always refers to the same handle
+type classDescKey struct {
+	className  string
+	suid       uint64
+	isProxy    bool
+	interfaces string //joined proxy interfaces
+}
+type enumKey struct {
+	className    string
+	constantName string
+}
+type classKey struct {
+	className string
+	suid      uint64
+}
+
+//referenceKey return the key to find the handle of val
+//对象及数组按Go指针判断是否为同一对象, 字符串按值去重;
+//classDesc, enum, java.lang.Class 在java中是单例, 按类名判断
+func referenceKey(val interface{}) (interface{}, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case *JavaTcString:
+		return string(*v), true
+	case *JavaTcClassDesc:
+		return classDescKey{v.ClassName, v.SerialVersionUID, v.IsProxy, strings.Join(v.ProxyInterfaces, ",")}, true
+	case *JavaTcEnum:
+		if len(v.Classes) == 0 {
+			return nil, false
+		}
+		return enumKey{v.Classes[0].ClassName, v.ConstantName}, true
+	case *JavaTcClass:
+		if v.ClassDesc == nil {
+			return nil, false
+		}
+		return classKey{v.ClassDesc.ClassName, v.ClassDesc.SerialVersionUID}, true
+	case nil:
+		return nil, false
+	}
+	//其他指针按identity
+	if reflect.TypeOf(val).Kind() == reflect.Ptr {
+		return val, true
+	}
+	return nil, false
+}
+
 //NewJavaReferencePool
-//new Java Reference Pool to hold the TC_REF Object
-func NewJavaReferencePool(poolSize int) []*JavaReferenceObject {
-	if poolSize < 10 {
-		poolSize = 1 << 1 //1024
+//new Java Reference Pool to hold the TC_REF Object, poolSize is just a hint of the handle count
+func NewJavaReferencePool(poolSize int) *JavaReferencePool {
+	if poolSize < 0 {
+		poolSize = 0
+	}
+	return &JavaReferencePool{
+		refs:  make(map[uint32]*JavaReferenceObject, poolSize),
+		index: make(map[interface{}]uint32, poolSize),
+		next:  INTBASE_WIRE_HANDLE,
+	}
+}
+
+//Add assign next handle to the reference value, return the handle
+func (pool *JavaReferencePool) Add(refType byte, refVal interface{}) uint32 {
+	handle := pool.next
+	pool.refs[handle] = &JavaReferenceObject{
+		RefType: refType,
+		Val:     refVal,
+	}
+	//the first handle wins, the same as java
+	if key, ok := referenceKey(refVal); ok {
+		if _, exists := pool.index[key]; !exists {
+			pool.index[key] = handle
+		}
+	}
+	pool.next++
+	return handle
+}
+
+//Lookup return the handle already assigned to val, by pointer identity for objects & arrays,
+//by value for strings, by class name for classDesc, enum & java.lang.Class
+func (pool *JavaReferencePool) Lookup(val interface{}) (uint32, bool) {
+	key, ok := referenceKey(val)
+	if !ok {
+		return 0, false
+	}
+	handle, ok := pool.index[key]
+	return handle, ok
+}
+
+//Get return the reference of handle, error for the handle not assigned yet
+func (pool *JavaReferencePool) Get(handle uint32) (*JavaReferenceObject, error) {
+	if handle < INTBASE_WIRE_HANDLE {
+		return nil, fmt.Errorf("%w: Invalid reference handle 0x%x, it should not be less than 0x%x", ErrBadReference, handle, INTBASE_WIRE_HANDLE)
+	}
+	if ref, ok := pool.refs[handle]; ok {
+		return ref, nil
+	}
+	return nil, fmt.Errorf("%w: Dangling reference handle 0x%x, only %d handles are assigned", ErrBadReference, handle, pool.Len())
+}
+
+//Find return the first handle whose reference matches, in protocol order
+func (pool *JavaReferencePool) Find(match func(ref *JavaReferenceObject) bool) (uint32, bool) {
+	for handle := uint32(INTBASE_WIRE_HANDLE); handle < pool.next; handle++ {
+		if ref := pool.refs[handle]; ref != nil && match(ref) {
+			return handle, true
+		}
 	}
-	refPool := make([]*JavaReferenceObject, poolSize) //128
-	return refPool
+	return 0, false
+}
+
+//Len return the count of assigned handles
+func (pool *JavaReferencePool) Len() int {
+	return len(pool.refs)
+}
+
+//Reset clear all the handles, next newHandle will start from INTBASE_WIRE_HANDLE again
+func (pool *JavaReferencePool) Reset() {
+	pool.refs = make(map[uint32]*JavaReferenceObject)
+	pool.index = make(map[interface{}]uint32)
+	pool.next = INTBASE_WIRE_HANDLE
 }
 
 //ReadNextJavaField read next java field desc
-func ReadNextJavaField(reader io.Reader, refs []*JavaReferenceObject) (*JavaField, error) {
+func ReadNextJavaField(reader io.Reader, refs *JavaReferencePool) (*JavaField, error) {
 	var jf = &JavaField{}
 	var err error
 	if jf.FieldType, err = ReadNextByte(reader); err != nil {
@@ -125,7 +259,7 @@ func ReadNextJavaField(reader io.Reader, refs []*JavaReferenceObject) (*JavaFiel
 		return nil, err
 	}
 
-	if jf.FieldName, err = ReadUTFString(reader, int(fNameLen)); err != nil {
+	if jf.FieldName, err = readUTFString(reader, int(fNameLen), refs.strict()); err != nil {
 		return nil, err
 	}
 	switch jf.FieldType {
@@ -141,22 +275,126 @@ func ReadNextJavaField(reader io.Reader, refs []*JavaReferenceObject) (*JavaFiel
 	return jf, nil
 }
 
-//AddReference add java reference object
-func AddReference(refs []*JavaReferenceObject, refType byte, refVal interface{}) {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	var i int
-	for i = 0; i < cap(refs); i++ {
-		if refs[i] == nil {
-			refs[i] = &JavaReferenceObject{
-				refType,
-				refVal,
-			}
-			StdLogger.Debug("[REFERENCE] [ADD] [%d] refType:0x%x, refVal:%v\n", i, refType, refVal)
-			return
+//AddReference add java reference object, return the newHandle
+func AddReference(refs *JavaReferencePool, refType byte, refVal interface{}) uint32 {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	handle := refs.Add(refType, refVal)
+	refs.logger().Debug("[REFERENCE] [ADD] [0x%x] refType:0x%x, refVal:%v\n", handle, refType, refVal)
+	return handle
+}
+
+//ResetReference clear all the handles, next newHandle will start from INTBASE_WIRE_HANDLE again
+func ResetReference(refs *JavaReferencePool) {
+	refs.Reset()
+	refs.logger().Debug("[REFERENCE] [RESET]\n")
+}
+
+//CountingReader buffered reader counting the bytes has been read, so we can tell where we are in the stream
+//it implements io.ByteScanner, the last byte can be unread once
+//it may read data from the underlying reader beyond the bytes requested, like bufio.Reader
+type CountingReader struct {
+	buf    *bufio.Reader
+	data   []byte //the whole stream in memory when buf is nil, Offset is the position in it, see DecodeBytes
+	Offset int64
+}
+
+//NewCountingReader new counting reader over reader, *bufio.Reader is used as it is, *CountingReader is returned directly
+func NewCountingReader(reader io.Reader) *CountingReader {
+	switch r := reader.(type) {
+	case *CountingReader:
+		return r
+	case *bufio.Reader:
+		return &CountingReader{buf: r}
+	default:
+		return &CountingReader{buf: bufio.NewReader(reader)}
+	}
+}
+
+//newBytesCountingReader counting reader over the bytes in memory, ReadNextBytes returns the sub slices of data without copying
+func newBytesCountingReader(data []byte) *CountingReader {
+	return &CountingReader{data: data}
+}
+
+//Read implements io.Reader
+func (cr *CountingReader) Read(p []byte) (int, error) {
+	if cr.buf == nil {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if cr.Offset >= int64(len(cr.data)) {
+			return 0, io.EOF
+		}
+		n := copy(p, cr.data[cr.Offset:])
+		cr.Offset += int64(n)
+		return n, nil
+	}
+	n, err := cr.buf.Read(p)
+	cr.Offset += int64(n)
+	return n, err
+}
+
+//ReadByte implements io.ByteReader
+func (cr *CountingReader) ReadByte() (byte, error) {
+	if cr.buf == nil {
+		if cr.Offset >= int64(len(cr.data)) {
+			return 0, io.EOF
+		}
+		cr.Offset += 1
+		return cr.data[cr.Offset-1], nil
+	}
+	b, err := cr.buf.ReadByte()
+	if err == nil {
+		cr.Offset += 1
+	}
+	return b, err
+}
+
+//UnreadByte implements io.ByteScanner
+func (cr *CountingReader) UnreadByte() error {
+	if cr.buf == nil {
+		if cr.Offset <= 0 {
+			return bufio.ErrInvalidUnreadByte
+		}
+	} else if err := cr.buf.UnreadByte(); err != nil {
+		return err
+	}
+	cr.Offset -= 1
+	return nil
+}
+
+//Peek return the next n bytes without advancing the reader, see bufio.Reader.Peek
+func (cr *CountingReader) Peek(n int) ([]byte, error) {
+	if cr.buf == nil {
+		rest := cr.data[cr.Offset:]
+		if len(rest) < n {
+			return rest, io.EOF
 		}
+		return rest[:n:n], nil
+	}
+	return cr.buf.Peek(n)
+}
+
+//next return the next n bytes of data without copying, aka io.ReadFull
+func (cr *CountingReader) next(n int) ([]byte, int, error) {
+	rest := cr.data[cr.Offset:]
+	if len(rest) < n {
+		cr.Offset = int64(len(cr.data))
+		if len(rest) == 0 {
+			return nil, 0, io.EOF
+		}
+		return nil, len(rest), io.ErrUnexpectedEOF
+	}
+	cr.Offset += int64(n)
+	return rest[:n:n], n, nil
+}
+
+//StreamOffset return the offset of the reader in the stream, -1 if unknown
+func StreamOffset(reader io.Reader) int64 {
+	if cr, ok := reader.(*CountingReader); ok {
+		return cr.Offset
 	}
-	StdLogger.Error("[REFERENCE] [ADD] There is no enough room for JavaReferenceObject, current cap %d\n", cap(refs))
+	return -1
 }
 
 //ReadUint16 read uint16, aka java short
@@ -186,42 +424,77 @@ func ReadUint64(reader io.Reader) (uint64, error) {
 	}
 }
 
-//ReadUTFString read utf8 string from the input stream
+//ReadUTFString read java modified utf8 string from the input stream, the malformed bytes are replaced by U+FFFD
 func ReadUTFString(reader io.Reader, len int) (string, error) {
+	return readUTFString(reader, len, false)
+}
+
+//readUTFString read java modified utf8 string, strict: the malformed bytes are error, see DecodeModifiedUTF8
+func readUTFString(reader io.Reader, len int, strict bool) (string, error) {
 	if bs, err := ReadNextBytes(reader, len); err != nil {
 		return "", err
 	} else {
-		return string(bs), nil
+		return DecodeModifiedUTF8(bs, strict)
 	}
-
 }
 
-//ReadNextBytes read next bytes from the stream
+//ReadNextBytes read exactly n bytes from the stream, aka DataInput.readFully
+//io.EOF if there is no byte at all, ErrTruncated if the stream ends in the middle
+//the bytes read by DecodeBytes are the sub slice of its data, they should not be modified
+//n is usually read from the stream, e.g. TC_LONGSTRING, so more than PRIM_ARRAY_CHUNK bytes are read in chunks
 func ReadNextBytes(reader io.Reader, n int) ([]byte, error) {
-	bs := make([]byte, n)
-	if c, err := reader.Read(bs); err != nil {
+	var bs []byte
+	var c int
+	var err error
+	if cr, ok := reader.(*CountingReader); ok && cr.buf == nil {
+		bs, c, err = cr.next(n)
+	} else if n <= PRIM_ARRAY_CHUNK {
+		bs = make([]byte, n)
+		c, err = io.ReadFull(reader, bs)
+	} else {
+		bs, c, err = readChunks(reader, n)
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("%w: Try to read %d bytes, but got %d bytes: %w", ErrTruncated, n, c, err)
+	} else if err != nil {
 		return nil, err
-	} else if c != n {
-		return nil, fmt.Errorf("Try to read %d bytes, but got %d bytes", n, c)
 	}
 	return bs, nil
 }
 
-//ReadNextByte read next byte from the stream
+//readChunks read n bytes in chunks of PRIM_ARRAY_CHUNK, the memory grows with the data actually read
+func readChunks(reader io.Reader, n int) ([]byte, int, error) {
+	bs := make([]byte, 0, PRIM_ARRAY_CHUNK)
+	for len(bs) < n {
+		c := min(n-len(bs), PRIM_ARRAY_CHUNK)
+		bs = slices.Grow(bs, c)
+		got, err := io.ReadFull(reader, bs[len(bs):len(bs)+c])
+		if err == io.EOF && len(bs) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, len(bs) + got, err
+		}
+		bs = bs[:len(bs)+c]
+	}
+	return bs, n, nil
+}
+
+//ReadNextByte read next byte from the stream, io.ByteReader is used if the reader implements it
 func ReadNextByte(reader io.Reader) (byte, error) {
-	var n = 1
-	bs := make([]byte, n)
-	if c, err := reader.Read(bs); err != nil {
+	if br, ok := reader.(io.ByteReader); ok {
+		return br.ReadByte()
+	}
+	var bs [1]byte
+	if _, err := io.ReadFull(reader, bs[:]); err != nil {
 		return 0, err
-	} else if c != n {
-		return 0, fmt.Errorf("Try to read %d bytes, but got %d bytes", n, c)
 	}
 	return bs[0], nil
 }
 
 //ReadNextTcString read next tc string
 //TC_STRING + string length + string
-func ReadNextTcString(reader io.Reader, refs []*JavaReferenceObject) (string, error) {
+func ReadNextTcString(reader io.Reader, refs *JavaReferencePool) (string, error) {
 	if b, err := ReadNextByte(reader); err != nil {
 		return "", err
 	} else if b == TC_REFERENCE {
@@ -229,22 +502,48 @@ func ReadNextTcString(reader io.Reader, refs []*JavaReferenceObject) (string, er
 		if refIndex, err := ReadUint32(reader); err != nil {
 			return "", err
 		} else {
-			var ref = refs[refIndex-0x007E0000]
-			if v, ok := ref.Val.(string); !ok {
-				return "", fmt.Errorf("Expected string, but got %v", ref.Val)
+			if ref, err := refs.Get(refIndex); err != nil {
+				return "", err
+			} else if v, ok := ref.Val.(string); !ok {
+				return "", fmt.Errorf("%w: Expected string, but got %v", ErrBadReference, ref.Val)
 			} else {
 				return v, nil
 			}
 		}
 	} else if b == TC_NULL { //考虑String为null的情况
 		return "", nil
-	} else if b != TC_STRING {
-		return "", fmt.Errorf("Expected 0x%x, but got 0x%x", TC_STRING, b)
+	} else if b == TC_EXCEPTION {
+		return "", ReadJavaException(reader, refs)
+	} else if b != TC_STRING && b != TC_LONGSTRING {
+		return "", typeCodeError(b, []byte{TC_STRING, TC_LONGSTRING, TC_NULL, TC_REFERENCE}, fmt.Errorf("Expected 0x%x, but got 0x%x", TC_STRING, b))
+	} else {
+		return ReadTcStringContent(b, reader, refs)
 	}
+}
 
-	if strLen, err := ReadUint16(reader); err != nil {
-		return "", err
-	} else if str, err := ReadUTFString(reader, int(strLen)); err != nil {
+//ReadTcStringContent read string content after TC_STRING or TC_LONGSTRING
+//TC_STRING newHandle (utf), TC_LONGSTRING newHandle (long-utf)
+func ReadTcStringContent(tc byte, reader io.Reader, refs *JavaReferencePool) (string, error) {
+	var strLen int
+	switch tc {
+	case TC_STRING:
+		if l, err := ReadUint16(reader); err != nil {
+			return "", err
+		} else {
+			strLen = int(l)
+		}
+	case TC_LONGSTRING:
+		if l, err := ReadUint64(reader); err != nil {
+			return "", err
+		} else if l > math.MaxInt32 {
+			return "", fmt.Errorf("TC_LONGSTRING length %d is too large", l)
+		} else {
+			strLen = int(l)
+		}
+	default:
+		return "", typeCodeError(tc, []byte{TC_STRING, TC_LONGSTRING}, fmt.Errorf("Expected TC_STRING or TC_LONGSTRING, but got 0x%x", tc))
+	}
+	if str, err := readUTFString(reader, strLen, refs.strict()); err != nil {
 		return "", err
 	} else {
 		//如果读出来原始的TC_STRING则产生一个新的 newHandle
@@ -254,53 +553,20 @@ func ReadNextTcString(reader io.Reader, refs []*JavaReferenceObject) (string, er
 }
 
 //DeserializeStream
-//deserialize stream to java object
+//deserialize stream to java object, only the first content is read
 func DeserializeStream(reader io.Reader) (JavaSerializer, error) {
-	//read magic
-	if b, err := ReadUint16(reader); err != nil {
-		return nil, err
-	} else if b != uint16(STREAM_MAGIC) {
-		return nil, fmt.Errorf("stream should start with STREAM_MAGIC but got 0x%x", b)
-	}
-	//read version
-	if b, err := ReadUint16(reader); err != nil {
-		return nil, err
-	} else if b != uint16(STREAM_VERSION) {
-		return nil, fmt.Errorf("stream should start with STREAM_VERSION but got 0x%x", b)
-	}
-
-	refs := NewJavaReferencePool(1 << 10) //make([]*JavaReferenceObject, 1000)
-
-	if b, err := ReadNextByte(reader); err != nil {
+	sr := NewJavaStreamReader(reader)
+	if content, err := sr.ReadContent(); err != nil {
 		return nil, err
 	} else {
-		switch b {
-		case TC_ARRAY:
-			tcArr := &JavaTcArray{}
-			if err = tcArr.Deserialize(reader, refs); err != nil {
-				return nil, err
-			} else {
-				return tcArr, nil
-			}
-		case TC_OBJECT:
-			tcJo := &JavaTcObject{}
-			if err = tcJo.Deserialize(reader, refs); err != nil {
-				return nil, err
-			} else {
-				return tcJo, nil
-			}
-		case TC_STRING:
-			tcStr := new(JavaTcString)
-			if err = tcStr.Deserialize(reader, refs); err != nil {
-				return nil, err
-			} else {
-				return tcStr, nil
-			}
+		switch content.Kind {
 		case TC_NULL: //表示空指针
-			StdLogger.Warn("Stream's body first byte is TC_NULL")
+			sr.refs.logger().Warn("Stream's body first byte is TC_NULL")
 			return new(JavaTcString), nil
+		case TC_BLOCKDATA:
+			return nil, fmt.Errorf("stream should be one of TC_ARRAY & TC_OBJECT & TC_ENUM & TC_CLASS & TC_STRING & TC_LONGSTRING, but got TC_BLOCKDATA")
 		default:
-			return nil, fmt.Errorf("stream should be one of TC_ARRAY & TC_OBJECT & TC_STRING, but got 0x%x", b)
+			return content.Entity, nil
 		}
 	}
 
@@ -309,14 +575,5 @@ func DeserializeStream(reader io.Reader) (JavaSerializer, error) {
 //SerializeJavaEntity
 //serialize java entity to stream
 func SerializeJavaEntity(writer io.Writer, entity JavaSerializer) error {
-	var err error
-	buff := make([]byte, 4)
-	binary.BigEndian.PutUint16(buff[:2], STREAM_MAGIC)
-	binary.BigEndian.PutUint16(buff[2:4], STREAM_VERSION)
-	if _, err = writer.Write(buff); err != nil {
-		return err
-	}
-	refs := NewJavaReferencePool(1 << 7)
-
-	return entity.Serialize(writer, refs)
+	return NewJavaStreamWriter(writer).WriteEntity(entity)
 }
@@ -0,0 +1,55 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "strings"
+import "sync"
+import "log/slog"
+
+func TestSlogHandler(t *testing.T) {
+	out := new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dec := NewDecoder(bytes.NewReader(javaStream(nodeGraph...)))
+	dec.SetLogger(NewSlogHandler(logger))
+	var js JavaSerializer
+	if err := dec.Decode(&js); err != nil {
+		t.Fatalf("Decode got %v\n", err)
+	}
+	if log := out.String(); !strings.Contains(log, "level=DEBUG") || !strings.Contains(log, "depth=2") {
+		t.Fatalf("Expect debug records with depth, but got\n%s\n", log)
+	}
+
+	out.Reset()
+	logger = slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	dec = NewDecoder(bytes.NewReader(javaStream(nodeGraph...)))
+	dec.SetLogger(NewSlogHandler(logger))
+	if err := dec.Decode(&js); err != nil || out.Len() != 0 {
+		t.Fatalf("Expect no debug records, but got %v\n%s\n", err, out.String())
+	}
+}
+
+func TestLoggerConcurrent(t *testing.T) {
+	out := new(bytes.Buffer)
+	handler := NewConsoleLogHandler(out, LOG_DEBUG)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dec := NewDecoder(bytes.NewReader(javaStream(nodeGraph...)))
+			dec.SetLogger(handler)
+			var js JavaSerializer
+			for j := 0; j < 3; j++ {
+				if err := dec.Decode(&js); err != nil {
+					t.Errorf("Decode got %v\n", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !strings.HasPrefix(line, COLOR_GREEN+"[") || !strings.HasSuffix(line, COLOR_RESET) {
+			t.Fatalf("Unexpected log line %q\n", line)
+		}
+	}
+}
@@ -1,15 +1,58 @@
-package main
+package javaserializer
 
+import "io"
+import "os"
 import "fmt"
+import "sync"
 import "time"
-import "david/util"
+import "context"
+import "strings"
+import "log/slog"
 
-var StdLogger = &Logger{
-	level: -1,
+//LogLevel level of the log records
+type LogLevel int
+
+const (
+	LOG_DEBUG LogLevel = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+var logLevelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (level LogLevel) String() string {
+	if level >= 0 && int(level) < len(logLevelNames) {
+		return logLevelNames[level]
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(level))
+}
+
+//LogHandler receive the log records of Decoder, Encoder etc.
+//one handler may be shared by the codecs running in different goroutines, so it must be goroutine safe
+type LogHandler interface {
+	Enabled(level LogLevel) bool
+	//Log depth is the nesting depth of the record, msg has no trailing newline
+	Log(level LogLevel, depth int, msg string)
 }
 
+//Logger the logger of one Decoder or Encoder, it is not shared between goroutines
+//LevelUp & LevelDown track the nesting depth of the codec, nothing is logged without handler
 type Logger struct {
-	level int //1个level对应4个空格
+	handler LogHandler
+	level   int //nesting depth
+}
+
+//NewLogger new logger with handler, nil handler means off
+func NewLogger(handler LogHandler) *Logger {
+	return &Logger{
+		handler: handler,
+	}
+}
+
+//SetHandler set the handler, nil to turn it off
+func (logger *Logger) SetHandler(handler LogHandler) {
+	logger.handler = handler
 }
 
 //LevelUp
@@ -20,48 +63,123 @@ func (logger *Logger) LevelUp() {
 //LevelDown
 func (logger *Logger) LevelDown() {
 	logger.level -= 1
-	if logger.level < 0 {
-		fmt.Errorf("logger.level should not less than 0 %d\n", logger.level)
+}
+
+//log format and send the record to the handler if it is enabled
+func (logger *Logger) log(level LogLevel, format string, a ...interface{}) {
+	if logger.handler == nil || !logger.handler.Enabled(level) {
+		return
 	}
+	logger.handler.Log(level, logger.level, strings.TrimRight(fmt.Sprintf(format, a...), "\n"))
 }
 
 //Debug print debug level
 func (logger *Logger) Debug(format string, a ...interface{}) {
-	util.ColorPrinter.Green("[%s DEBUG]:", NowTime())
-	for i := 0; i < logger.level; i++ {
-		util.ColorPrinter.Green("    ")
-	}
-	util.ColorPrinter.Green(format, a...)
+	logger.log(LOG_DEBUG, format, a...)
 }
 
-//Info print debug level
+//Info print info level
 func (logger *Logger) Info(format string, a ...interface{}) {
-	util.ColorPrinter.Cyan("[%s INFO]:", NowTime())
-	for i := 0; i < logger.level; i++ {
-		util.ColorPrinter.Cyan("    ")
-	}
-	util.ColorPrinter.Cyan(format, a...)
+	logger.log(LOG_INFO, format, a...)
 }
 
-//Warn print debug level
+//Warn print warn level
 func (logger *Logger) Warn(format string, a ...interface{}) {
-	util.ColorPrinter.Yellow("[%s WARN]:", NowTime())
-	for i := 0; i < logger.level; i++ {
-		util.ColorPrinter.Yellow("    ")
-	}
-	util.ColorPrinter.Yellow(format, a...)
+	logger.log(LOG_WARN, format, a...)
 }
 
-//Error print debug level
+//Error print error level
 func (logger *Logger) Error(format string, a ...interface{}) {
-	util.ColorPrinter.Red("[%s ERROR]:", NowTime())
-	for i := 0; i < logger.level; i++ {
-		util.ColorPrinter.Red("    ")
+	logger.log(LOG_ERROR, format, a...)
+}
+
+//ANSI colors of the log levels
+const (
+	COLOR_RED    = "\x1b[31m"
+	COLOR_GREEN  = "\x1b[32m"
+	COLOR_YELLOW = "\x1b[33m"
+	COLOR_CYAN   = "\x1b[36m"
+	COLOR_RESET  = "\x1b[0m"
+)
+
+var logLevelColors = []string{COLOR_GREEN, COLOR_CYAN, COLOR_YELLOW, COLOR_RED}
+
+//ConsoleLogHandler print colored records, 1个depth对应4个空格
+type ConsoleLogHandler struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level LogLevel //min level printed
+}
+
+//NewConsoleLogHandler new console handler writing to w, os.Stdout if w is nil
+func NewConsoleLogHandler(w io.Writer, level LogLevel) *ConsoleLogHandler {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &ConsoleLogHandler{
+		w:     w,
+		level: level,
 	}
-	util.ColorPrinter.Red(format, a...)
 }
 
-//nowTime hh:mm:ss
+//Enabled implements LogHandler
+func (handler *ConsoleLogHandler) Enabled(level LogLevel) bool {
+	return level >= handler.level
+}
+
+//Log implements LogHandler
+func (handler *ConsoleLogHandler) Log(level LogLevel, depth int, msg string) {
+	color := COLOR_RESET
+	if level >= 0 && int(level) < len(logLevelColors) {
+		color = logLevelColors[level]
+	}
+	if depth < 0 {
+		depth = 0
+	}
+	handler.lock.Lock()
+	defer handler.lock.Unlock()
+	fmt.Fprintf(handler.w, "%s[%s %s]:%s%s%s\n", color, NowTime(), level, strings.Repeat("    ", depth), msg, COLOR_RESET)
+}
+
+//slogHandler adapter of log/slog
+type slogHandler struct {
+	logger *slog.Logger
+}
+
+//NewSlogHandler new LogHandler logging to the slog logger, the nesting depth is the attribute "depth"
+func NewSlogHandler(logger *slog.Logger) LogHandler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogHandler{
+		logger: logger,
+	}
+}
+
+//slogLevel map LogLevel to slog.Level
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LOG_DEBUG:
+		return slog.LevelDebug
+	case LOG_INFO:
+		return slog.LevelInfo
+	case LOG_WARN:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+//Enabled implements LogHandler
+func (handler *slogHandler) Enabled(level LogLevel) bool {
+	return handler.logger.Enabled(context.Background(), slogLevel(level))
+}
+
+//Log implements LogHandler
+func (handler *slogHandler) Log(level LogLevel, depth int, msg string) {
+	handler.logger.Log(context.Background(), slogLevel(level), msg, slog.Int("depth", depth))
+}
+
+//NowTime the current time in hh:mm:ss, used as the time of ConsoleLogHandler records
 func NowTime() string {
 	now := time.Now()
 	return fmt.Sprintf("%02d:%02d:%02d", now.Hour(), now.Minute(), now.Second())
@@ -1,10 +1,11 @@
-package main
+package javaserializer
 
 import "testing"
 import "os"
 import "reflect"
 import "math"
 import "encoding/json"
+import "path/filepath"
 
 func TestSlice(t *testing.T) {
 
@@ -23,6 +24,34 @@ func TestSlice(t *testing.T) {
 
 }
 
+//testDataDir 由java写出的测试数据所在的目录, 可由环境变量 JAVA_SERIALIZER_TESTDATA 指定
+func testDataDir() string {
+	if dir := os.Getenv("JAVA_SERIALIZER_TESTDATA"); dir != "" {
+		return dir
+	}
+	return "d:\\tmp"
+}
+
+//openTestData open the data written by java, the test is skipped if it does not exist
+func openTestData(t *testing.T, name string) *os.File {
+	f, err := os.Open(filepath.Join(testDataDir(), name))
+	if os.IsNotExist(err) {
+		t.Skipf("%s not found in %s, set JAVA_SERIALIZER_TESTDATA to run this test\n", name, testDataDir())
+	} else if err != nil {
+		t.Fatalf("got error when open file %v\n", err)
+	}
+	return f
+}
+
+//createTestData create the file to write the data for java, in the temp dir of the test
+func createTestData(t *testing.T, name string) *os.File {
+	f, err := os.Create(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("got error when open file %v\n", err)
+	}
+	return f
+}
+
 func changeSlice(arr []byte) {
 	arr = append(arr, 0x01)
 }
@@ -31,9 +60,7 @@ func TestJavaTcObject(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.Open("d:\\tmp\\serialize-child.data"); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = openTestData(t, "serialize-child.data")
 	defer f.Close()
 	arr := make([]byte, 1<<7) //128
 
@@ -41,7 +68,7 @@ func TestJavaTcObject(t *testing.T) {
 		//to be continued...
 		t.Fatalf("Got error %v\n", err)
 	}
-	refs := make([]*JavaReferenceObject, 1<<7)
+	refs := NewJavaReferencePool(1 << 7)
 	jo := &JavaTcObject{}
 	if err = jo.Deserialize(f, refs); err != nil {
 		t.Fatalf("When deserialize JavaTcObject got %v\n", err)
@@ -60,9 +87,7 @@ func TestJavaDeserialize(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.Open("d:\\tmp\\serialize-child.data"); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = openTestData(t, "serialize-child.data")
 	defer f.Close()
 
 	var v JavaSerializer
@@ -113,9 +138,7 @@ func TestObjectSerialize(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.OpenFile("d:\\tmp\\serialize-go.data", os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = createTestData(t, "serialize-go.data")
 	defer f.Close()
 
 	if err = SerializeJavaEntity(f, jo); err != nil {
@@ -1,4 +1,4 @@
-package main
+package javaserializer
 
 import "testing"
 import "os"
@@ -17,9 +17,7 @@ func TestHashMap(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.OpenFile("d:\\tmp\\serialize-go-map.data", os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = createTestData(t, "serialize-go-map.data")
 	defer f.Close()
 
 	if err = SerializeJavaEntity(f, jo); err != nil {
@@ -44,9 +42,7 @@ func TestLinkedHashMap(t *testing.T) {
 	var f *os.File
 	var err error
 
-	if f, err = os.OpenFile("d:\\tmp\\serialize-go-linkedmap.data", os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = createTestData(t, "serialize-go-linkedmap.data")
 	defer f.Close()
 
 	if err = SerializeJavaEntity(f, jo); err != nil {
@@ -56,13 +52,16 @@ func TestLinkedHashMap(t *testing.T) {
 	}
 }
 func TestLinkedHashMap2(t *testing.T) {
-	jo := NewLinkedHashMap(map[string]interface{}{"a": "b", "c": "d"})
+	jo, err := NewLinkedHashMap(map[string]interface{}{"a": "b", "c": "d"})
+	if err != nil {
+		t.Fatalf("NewLinkedHashMap got %v\n", err)
+	}
+	if _, err = NewLinkedHashMap(map[string]interface{}{"a": []int{1}}); err == nil {
+		t.Fatalf("Expect error of unsupported value []int\n")
+	}
 	var f *os.File
-	var err error
 
-	if f, err = os.OpenFile("d:\\tmp\\serialize-go-linkedmap2.data", os.O_CREATE|os.O_TRUNC, 0755); err != nil {
-		t.Fatalf("got error when open file %v\n", err)
-	}
+	f = createTestData(t, "serialize-go-linkedmap2.data")
 	defer f.Close()
 
 	if err = SerializeJavaEntity(f, jo); err != nil {
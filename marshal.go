@@ -0,0 +1,449 @@
+package javaserializer
+
+import "fmt"
+import "bytes"
+import "sort"
+import "strconv"
+import "strings"
+import "reflect"
+import "unicode"
+import "unicode/utf8"
+
+// Marshal 按反射将Go的值生成java对象图, 规则如下:
+// 	bool                          -> boolean
+// 	int8 uint8                    -> byte
+// 	int16 uint16                  -> short, tag 选项 char 时为 char
+// 	int32 uint32                  -> int, tag 选项 char 时为 char
+// 	int int64 uint uint64         -> long
+// 	float32 float64               -> float double
+// 	string                        -> String
+// 	指向以上类型的指针            -> Byte Short Integer Long Float Double Boolean Character, nil 为null
+// 	struct 及其指针               -> object, 类名及serialVersionUID 由名为 _ 的field的tag给出:
+// 	                                 _ struct{} `java:"com.example.Order,serialVersionUID=1"`
+// 	                                 field名由 `java:"name"` 给出, 没有tag时为首字母小写的Go field名, "-" 表示忽略
+// 	                                 有类名的匿名嵌入struct为父类, 没有类名的匿名嵌入struct其field并入当前类
+// 	slice array                   -> 数组, tag 选项 list 时为 java.util.ArrayList
+// 	map                           -> java.util.HashMap, 按key排序写入
+// 	interface{}                   -> 按其实际的值
+// 	JavaSerializer                -> 原样写入
+// 	TypeRegistry 中注册了GoType的struct -> 注册的类名及serialVersionUID, 不需要 _ field
+// 同一个struct指针只生成一个java对象, 环形引用写为TC_REFERENCE
+
+//Marshal encode v as a java serialization stream with one content, like encoding/json.Marshal
+func Marshal(v interface{}) ([]byte, error) {
+	buff := new(bytes.Buffer)
+	if err := NewEncoder(buff).Encode(v); err != nil {
+		return nil, err
+	}
+	return buff.Bytes(), nil
+}
+
+//javaTagOptions options of the `java:"name,opt..."` tag
+type javaTagOptions []string
+
+func (opts javaTagOptions) has(opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+//marshaler state of one Marshal
+type marshaler struct {
+	objs  map[marshalKey]*JavaTcObject //struct pointer -> the java object already created
+	types *TypeRegistry                //class names of the Go types without tag
+}
+
+type marshalKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+//marshalEntity build the java entity of v
+func marshalEntity(v interface{}, registry *TypeRegistry) (JavaSerializer, error) {
+	m := &marshaler{
+		objs:  make(map[marshalKey]*JavaTcObject),
+		types: registry,
+	}
+	val, err := m.object(reflect.ValueOf(v), nil, "root")
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, fmt.Errorf("Cannot marshal nil %T", v)
+	}
+	return val, nil
+}
+
+//javaType return the prim typecode or the JVM signature of Go type t, e.g. Ljava/lang/String; [I
+func (m *marshaler) javaType(t reflect.Type, opts javaTagOptions) (byte, string, error) {
+	if t.Implements(javaSerializerType) {
+		return TC_OBJ_OBJECT, "Ljava/lang/Object;", nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return TC_PRIM_BOOLEAN, "", nil
+	case reflect.Int8, reflect.Uint8:
+		return TC_PRIM_BYTE, "", nil
+	case reflect.Int16, reflect.Uint16, reflect.Int32, reflect.Uint32:
+		if opts.has("char") {
+			return TC_PRIM_CHAR, "", nil
+		}
+		if t.Kind() == reflect.Int16 || t.Kind() == reflect.Uint16 {
+			return TC_PRIM_SHORT, "", nil
+		}
+		return TC_PRIM_INTEGER, "", nil
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return TC_PRIM_LONG, "", nil
+	case reflect.Float32:
+		return TC_PRIM_FLOAT, "", nil
+	case reflect.Float64:
+		return TC_PRIM_DOUBLE, "", nil
+	case reflect.String:
+		return TC_OBJ_OBJECT, "Ljava/lang/String;", nil
+	case reflect.Interface:
+		return TC_OBJ_OBJECT, "Ljava/lang/Object;", nil
+	case reflect.Map:
+		return TC_OBJ_OBJECT, "Ljava/util/HashMap;", nil
+	case reflect.Slice, reflect.Array:
+		if opts.has("list") {
+			return TC_OBJ_OBJECT, "Ljava/util/ArrayList;", nil
+		}
+		tc, sig, err := m.javaType(t.Elem(), opts)
+		if err != nil {
+			return 0, "", err
+		}
+		if sig == "" {
+			sig = string([]byte{tc})
+		}
+		return TC_OBJ_ARRAY, "[" + sig, nil
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Struct {
+			return m.javaType(t.Elem(), opts)
+		}
+		if tc, sig, err := m.javaType(t.Elem(), opts); err != nil {
+			return 0, "", err
+		} else if sig != "" {
+			return 0, "", fmt.Errorf("Unsupported pointer type %s", t)
+		} else {
+			return TC_OBJ_OBJECT, "L" + strings.Replace(boxedClasses[tc].className, ".", "/", -1) + ";", nil
+		}
+	case reflect.Struct:
+		if className, _, ok := m.javaClassOf(t); ok {
+			return TC_OBJ_OBJECT, "L" + strings.Replace(className, ".", "/", -1) + ";", nil
+		}
+		return 0, "", fmt.Errorf("No java class name for %s, it should be given by the tag of field _ or RegisterClass", t)
+	}
+	return 0, "", fmt.Errorf("Unsupported type %s", t)
+}
+
+var javaSerializerType = reflect.TypeOf((*JavaSerializer)(nil)).Elem()
+
+//javaClassOf return the class name and serialVersionUID given by the tag of field _, or registered by TypeRegistry.RegisterClass
+func (m *marshaler) javaClassOf(t reflect.Type) (string, uint64, bool) {
+	sf, ok := t.FieldByName("_")
+	var name string
+	var opts []string
+	if ok && len(sf.Index) == 1 {
+		name, opts = parseJavaTag(sf)
+	}
+	if name == "" {
+		for _, gt := range []reflect.Type{t, reflect.PtrTo(t)} {
+			if className, handler, ok := m.types.LookupGoType(gt); ok {
+				return className, handler.SerialVersionUID, true
+			}
+		}
+		return "", 0, false
+	}
+	var suid uint64
+	for _, opt := range opts {
+		if !strings.HasPrefix(opt, "serialVersionUID=") {
+			continue
+		}
+		s := strings.TrimPrefix(opt, "serialVersionUID=")
+		if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+			suid = uint64(i)
+		} else if u, err := strconv.ParseUint(s, 0, 64); err == nil {
+			suid = u
+		}
+	}
+	return name, suid, true
+}
+
+//prim return the JavaField.FieldValue of the prim typecode
+func (m *marshaler) prim(tc byte, rv reflect.Value) interface{} {
+	switch tc {
+	case TC_PRIM_BOOLEAN:
+		return rv.Bool()
+	case TC_PRIM_FLOAT:
+		return float32(rv.Float())
+	case TC_PRIM_DOUBLE:
+		return rv.Float()
+	}
+	var bits uint64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits = uint64(rv.Int())
+	default:
+		bits = rv.Uint()
+	}
+	switch tc {
+	case TC_PRIM_BYTE:
+		return byte(bits)
+	case TC_PRIM_CHAR:
+		return rune(uint16(bits))
+	case TC_PRIM_SHORT:
+		return uint16(bits)
+	case TC_PRIM_INTEGER:
+		return uint32(bits)
+	}
+	return bits
+}
+
+//value return the JavaField.FieldValue or array element of rv
+func (m *marshaler) value(rv reflect.Value, opts javaTagOptions, path string) (interface{}, error) {
+	tc, _, err := m.javaType(rv.Type(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot marshal %s at %s: %v", rv.Type(), path, err)
+	}
+	if IsPrimType(tc) {
+		return m.prim(tc, rv), nil
+	}
+	if rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	js, err := m.object(rv, opts, path)
+	if err != nil || js == nil {
+		return nil, err //nil JavaSerializer 转为 nil interface{}
+	}
+	return js, nil
+}
+
+//object return the java object of rv, nil for Go nil
+func (m *marshaler) object(rv reflect.Value, opts javaTagOptions, path string) (JavaSerializer, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.Type().Implements(javaSerializerType) {
+		if (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil() {
+			return nil, nil
+		}
+		return rv.Interface().(JavaSerializer), nil
+	}
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return m.object(rv.Elem(), opts, path)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Elem().Kind() != reflect.Struct {
+			tc, _, err := m.javaType(rv.Elem().Type(), opts)
+			if err != nil || !IsPrimType(tc) {
+				return nil, fmt.Errorf("Cannot marshal %s at %s", rv.Type(), path)
+			}
+			return NewBoxedObject(tc, m.prim(tc, rv.Elem())), nil
+		}
+		key := marshalKey{rv.Pointer(), rv.Type()}
+		if jo, ok := m.objs[key]; ok {
+			return jo, nil
+		}
+		jo := &JavaTcObject{}
+		m.objs[key] = jo
+		return jo, m.fillObject(jo, rv.Elem(), path)
+	case reflect.Struct:
+		jo := &JavaTcObject{}
+		return jo, m.fillObject(jo, rv, path)
+	case reflect.String:
+		return NewJavaTcString(rv.String()), nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return m.hashMap(rv, path)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		if opts.has("list") {
+			eles := make([]interface{}, rv.Len())
+			for i := range eles {
+				if js, err := m.object(rv.Index(i), opts.without("list"), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return nil, err
+				} else if js != nil {
+					eles[i] = js
+				}
+			}
+			return NewArrayList(eles), nil
+		}
+		return m.array(rv, opts, path)
+	}
+	//其他基本类型按包装类型写入
+	tc, _, err := m.javaType(rv.Type(), opts)
+	if err != nil || !IsPrimType(tc) {
+		return nil, fmt.Errorf("Cannot marshal %s at %s", rv.Type(), path)
+	}
+	return NewBoxedObject(tc, m.prim(tc, rv)), nil
+}
+
+func (opts javaTagOptions) without(opt string) javaTagOptions {
+	others := make(javaTagOptions, 0, len(opts))
+	for _, o := range opts {
+		if o != opt {
+			others = append(others, o)
+		}
+	}
+	return others
+}
+
+//array build the java array of slice or array rv
+func (m *marshaler) array(rv reflect.Value, opts javaTagOptions, path string) (*JavaTcArray, error) {
+	_, sig, err := m.javaType(rv.Type(), opts)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot marshal %s at %s: %v", rv.Type(), path, err)
+	}
+	className := strings.Replace(sig, "/", ".", -1)
+	suid := ArraySerialVersionUID(className)
+	tcArr := NewJavaTcArray(suid)
+	tcArr.ClassDesc = NewJavaTcClassDesc(className, suid, SC_SERIALIZABLE)
+	if eleType, ok := primArrayType(className); ok {
+		prims := makePrimArray(eleType, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err = setPrimElement(prims, i, rv.Index(i)); err != nil {
+				return nil, fmt.Errorf("Cannot marshal %s at %s[%d]: %v", rv.Type(), path, i, err)
+			}
+		}
+		tcArr.Prims = prims.Interface()
+		return tcArr, nil
+	}
+	tcArr.Values = make([]interface{}, rv.Len())
+	for i := range tcArr.Values {
+		if tcArr.Values[i], err = m.value(rv.Index(i), opts, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return nil, err
+		}
+	}
+	return tcArr, nil
+}
+
+//hashMap build java.util.HashMap of map rv, the entries are sorted by key
+func (m *marshaler) hashMap(rv reflect.Value, path string) (*JavaTcObject, error) {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	items := make([]interface{}, 0, len(keys)*2)
+	for _, k := range keys {
+		kp := fmt.Sprintf("%s[%v]", path, k.Interface())
+		kjs, err := m.object(k, nil, kp)
+		if err != nil {
+			return nil, err
+		}
+		vjs, err := m.object(rv.MapIndex(k), nil, kp)
+		if err != nil {
+			return nil, err
+		}
+		var kv, vv interface{}
+		if kjs != nil {
+			kv = kjs
+		}
+		if vjs != nil {
+			vv = vjs
+		}
+		items = append(items, kv, vv)
+	}
+	jo := NewJavaTcObject(SID_HASH_MAP)
+	jo.AddClassDesc(GenerateHashMapClassDesc(items))
+	return jo, nil
+}
+
+//fillObject build the classes of struct rv into jo
+func (m *marshaler) fillObject(jo *JavaTcObject, rv reflect.Value, path string) error {
+	className, suid, ok := m.javaClassOf(rv.Type())
+	if !ok {
+		return fmt.Errorf("Cannot marshal %s at %s: no java class name, it should be given by the tag of field _ or RegisterClass", rv.Type(), path)
+	}
+	cd := NewJavaTcClassDesc(className, suid, SC_SERIALIZABLE)
+	jo.SerialVersionUID = suid
+	jo.Classes = []*JavaTcClassDesc{cd}
+	var super reflect.Value
+	if err := m.fields(cd, rv, path, &super); err != nil {
+		return err
+	}
+	//java的field顺序: 基本类型在前, 再按名字排序
+	sort.SliceStable(cd.Fields, func(i, j int) bool {
+		pi, pj := IsPrimType(cd.Fields[i].FieldType), IsPrimType(cd.Fields[j].FieldType)
+		if pi != pj {
+			return pi
+		}
+		return cd.Fields[i].FieldName < cd.Fields[j].FieldName
+	})
+	if super.IsValid() {
+		superObj := &JavaTcObject{}
+		if err := m.fillObject(superObj, super, path); err != nil {
+			return err
+		}
+		cd.SuperClass = superObj.Classes[0]
+		jo.Classes = append(jo.Classes, superObj.Classes...)
+	}
+	return nil
+}
+
+//fields add the fields of struct rv to cd, the embedded struct with class name is returned as super
+func (m *marshaler) fields(cd *JavaTcClassDesc, rv reflect.Value, path string, super *reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, opts := parseJavaTag(sf)
+		if sf.Name == "_" || name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous && name == "" {
+			st := sf.Type
+			if st.Kind() == reflect.Ptr {
+				st = st.Elem()
+				if fv.IsNil() {
+					fv = reflect.Zero(st)
+				} else {
+					fv = fv.Elem()
+				}
+			}
+			if st.Kind() == reflect.Struct {
+				if _, _, ok := m.javaClassOf(st); !ok {
+					if err := m.fields(cd, fv, path, super); err != nil {
+						return err
+					}
+				} else if super.IsValid() {
+					return fmt.Errorf("Cannot marshal %s at %s: more than one super class", rt, path)
+				} else {
+					*super = fv
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" { //unexported
+			continue
+		}
+		if name == "" {
+			r, size := utf8.DecodeRuneInString(sf.Name)
+			name = string(unicode.ToLower(r)) + sf.Name[size:]
+		}
+		fieldPath := path + "." + name
+		tc, sig, err := m.javaType(sf.Type, opts)
+		if err != nil {
+			return fmt.Errorf("Cannot marshal %s at %s: %v", sf.Type, fieldPath, err)
+		}
+		jf := NewJavaField(tc, name, nil)
+		jf.FieldObjectClassName = sig
+		if jf.FieldValue, err = m.value(fv, opts, fieldPath); err != nil {
+			return err
+		}
+		cd.AddField(jf)
+	}
+	return nil
+}
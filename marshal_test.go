@@ -0,0 +1,102 @@
+package javaserializer
+
+import "testing"
+import "reflect"
+
+type marshalBase struct {
+	_  struct{} `java:"com.example.Base,serialVersionUID=0x10"`
+	Id int64
+}
+
+type marshalItem struct {
+	_     struct{} `java:"com.example.Item,serialVersionUID=2"`
+	Name  string
+	Count int32
+}
+
+type marshalOrder struct {
+	_ struct{} `java:"com.example.Order,serialVersionUID=-1"`
+	marshalBase
+	Code     string
+	Flag     bool
+	Grade    uint16 `java:"grade,char"`
+	Price    float64
+	Items    []*marshalItem
+	Tags     []string `java:"tags,list"`
+	Props    map[string]int32
+	Discount *int32
+	Parent   *marshalOrder
+	Skip     string `java:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	discount := int32(5)
+	order := &marshalOrder{
+		marshalBase: marshalBase{Id: 7},
+		Code:        "A01",
+		Flag:        true,
+		Grade:       'B',
+		Price:       9.5,
+		Items:       []*marshalItem{{Name: "pen", Count: 2}, nil},
+		Tags:        []string{"x", "y"},
+		Props:       map[string]int32{"a": 1, "b": 2},
+		Discount:    &discount,
+		Skip:        "skip",
+	}
+	order.Parent = order
+	data, err := Marshal(order)
+	if err != nil {
+		t.Fatalf("Marshal got %v\n", err)
+	}
+
+	var js JavaSerializer
+	if err := Unmarshal(data, &js); err != nil {
+		t.Fatalf("Unmarshal got %v\n", err)
+	}
+	jo := js.(*JavaTcObject)
+	if len(jo.Classes) != 2 || jo.Classes[0].ClassName != "com.example.Order" || jo.Classes[1].ClassName != "com.example.Base" {
+		t.Fatalf("Expect Order extends Base, but got %v\n", jo.Classes)
+	}
+	if jo.Classes[0].SerialVersionUID != 0xFFFFFFFFFFFFFFFF || jo.Classes[1].SerialVersionUID != 0x10 {
+		t.Fatalf("Unexpected serialVersionUID %x %x\n", jo.Classes[0].SerialVersionUID, jo.Classes[1].SerialVersionUID)
+	}
+	var names []string
+	for _, jf := range jo.Classes[0].Fields {
+		names = append(names, string(jf.FieldType)+jf.FieldName)
+	}
+	expect := []string{"Zflag", "Cgrade", "Dprice", "Lcode", "Ldiscount", "[items", "Lparent", "Lprops", "Ltags"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Fatalf("Expect fields %v, but got %v\n", expect, names)
+	}
+
+	var got marshalOrder
+	if err := Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal got %v\n", err)
+	}
+	if got.Parent != &got {
+		t.Fatalf("Expect parent is the order itself\n")
+	}
+	got.Parent, order.Parent = nil, nil
+	order.Skip = ""
+	if !reflect.DeepEqual(&got, order) {
+		t.Fatalf("Expect %+v, but got %+v\n", order, &got)
+	}
+}
+
+func TestArraySerialVersionUID(t *testing.T) {
+	for name, suid := range map[string]uint64{
+		"[Z":                  SID_BOOLEAN_ARRAY,
+		"[B":                  SID_BYTE_ARRAY,
+		"[C":                  SID_CHAR_ARRAY,
+		"[S":                  SID_SHORT_ARRAY,
+		"[I":                  SID_INT_ARRAY,
+		"[J":                  SID_LONG_ARRAY,
+		"[F":                  SID_FLOAT_ARRAY,
+		"[D":                  SID_DOUBLE_ARRAY,
+		"[Ljava.lang.String;": SID_STRING_ARRAY,
+	} {
+		if got := ArraySerialVersionUID(name); got != suid {
+			t.Fatalf("Expect %s 0x%X, but got 0x%X\n", name, suid, got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package javaserializer
+
+import "fmt"
+import "unicode/utf8"
+import "unicode/utf16"
+
+//java modified UTF-8, used by DataOutput.writeUTF and the serialization protocol
+//与标准UTF-8的区别:
+//	U+0000 编码为二个字节 0xC0 0x80, 因此编码结果中不会出现 0x00
+//	U+10000 以上的字符先拆为UTF-16代理对, 每个代理各自按3字节编码, 共6字节
+//	不会出现4字节的编码形式
+//2018-02-05 10:21:37 davidwang2006@aliyun.com
+
+//ModifiedUTF8Len return the length of str encoded in modified UTF-8
+func ModifiedUTF8Len(str string) int {
+	n := 0
+	for _, r := range str {
+		switch {
+		case r == 0:
+			n += 2
+		case r < 0x80:
+			n += 1
+		case r < 0x800:
+			n += 2
+		case r < 0x10000:
+			n += 3
+		default: //surrogate pair
+			n += 6
+		}
+	}
+	return n
+}
+
+//EncodeModifiedUTF8 encode go string to java modified UTF-8 bytes
+//invalid UTF-8 in str is encoded as U+FFFD
+func EncodeModifiedUTF8(str string) []byte {
+	//fast path, pure ascii without U+0000
+	i := 0
+	for i < len(str) && str[i] > 0 && str[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(str) {
+		return []byte(str)
+	}
+	bs := make([]byte, 0, ModifiedUTF8Len(str))
+	for _, r := range str {
+		switch {
+		case r == 0:
+			bs = append(bs, 0xC0, 0x80)
+		case r < 0x80:
+			bs = append(bs, byte(r))
+		case r < 0x800:
+			bs = append(bs, 0xC0|byte(r>>6), 0x80|byte(r&0x3F))
+		case r < 0x10000:
+			bs = appendUTF16Unit(bs, r)
+		default:
+			r1, r2 := utf16.EncodeRune(r)
+			bs = appendUTF16Unit(appendUTF16Unit(bs, r1), r2)
+		}
+	}
+	return bs
+}
+
+//encodeUTFString encode str in modified UTF-8, strict 为true时 str 中非法的UTF-8返回error, 否则编码为U+FFFD
+func encodeUTFString(str string, strict bool) ([]byte, error) {
+	if strict && !utf8.ValidString(str) {
+		return nil, fmt.Errorf("Invalid UTF-8 in %q", str)
+	}
+	return EncodeModifiedUTF8(str), nil
+}
+
+//appendUTF16Unit append one UTF-16 unit in 3 bytes form
+func appendUTF16Unit(bs []byte, r rune) []byte {
+	return append(bs, 0xE0|byte(r>>12), 0x80|byte((r>>6)&0x3F), 0x80|byte(r&0x3F))
+}
+
+//DecodeModifiedUTF8 decode java modified UTF-8 bytes to go string
+//strict 为true时, 遇到以下情况返回error; 否则以U+FFFD代替
+//	非法的首字节或后续字节, 被截断的编码
+//	0x00 字节, 超长(overlong)编码 (0xC0 0x80 除外)
+//	未配对的UTF-16代理, go string 无法表示
+func DecodeModifiedUTF8(bs []byte, strict bool) (string, error) {
+	//fast path, pure ascii without 0x00
+	i := 0
+	for i < len(bs) && bs[i] > 0 && bs[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(bs) {
+		return string(bs), nil
+	}
+	buf := make([]byte, i, len(bs)+len(bs)/2)
+	copy(buf, bs[:i])
+	var high rune = -1 //pending high surrogate
+	for i < len(bs) {
+		pos := i
+		b := bs[i]
+		var c rune
+		var bad bool
+		switch {
+		case b < 0x80:
+			c = rune(b)
+			bad = b == 0
+			i += 1
+		case b&0xE0 == 0xC0:
+			if i+1 >= len(bs) || bs[i+1]&0xC0 != 0x80 {
+				c, bad = utf8.RuneError, true
+				i += 1
+				break
+			}
+			c = rune(b&0x1F)<<6 | rune(bs[i+1]&0x3F)
+			bad = c != 0 && c < 0x80
+			i += 2
+		case b&0xF0 == 0xE0:
+			if i+2 >= len(bs) || bs[i+1]&0xC0 != 0x80 || bs[i+2]&0xC0 != 0x80 {
+				c, bad = utf8.RuneError, true
+				i += 1
+				break
+			}
+			c = rune(b&0x0F)<<12 | rune(bs[i+1]&0x3F)<<6 | rune(bs[i+2]&0x3F)
+			bad = c < 0x800
+			i += 3
+		default:
+			c, bad = utf8.RuneError, true
+			i += 1
+		}
+		//lenient 模式下与java DataInputStream.readUTF一致, 接受0x00及超长编码
+		if bad && strict {
+			return "", fmt.Errorf("Malformed modified UTF-8 byte 0x%x at offset %d", b, pos)
+		}
+		//combine the surrogate pair
+		if utf16.IsSurrogate(c) && c < 0xDC00 {
+			if high >= 0 {
+				if strict {
+					return "", fmt.Errorf("Unpaired high surrogate 0x%x before offset %d", high, pos)
+				}
+				buf = utf8.AppendRune(buf, utf8.RuneError)
+			}
+			high = c
+			continue
+		}
+		if utf16.IsSurrogate(c) {
+			if high < 0 {
+				if strict {
+					return "", fmt.Errorf("Unpaired low surrogate 0x%x at offset %d", c, pos)
+				}
+				c = utf8.RuneError
+			} else {
+				c = utf16.DecodeRune(high, c)
+				high = -1
+			}
+		} else if high >= 0 {
+			if strict {
+				return "", fmt.Errorf("Unpaired high surrogate 0x%x before offset %d", high, pos)
+			}
+			buf = utf8.AppendRune(buf, utf8.RuneError)
+			high = -1
+		}
+		buf = utf8.AppendRune(buf, c)
+	}
+	if high >= 0 {
+		if strict {
+			return "", fmt.Errorf("Unpaired high surrogate 0x%x at the end", high)
+		}
+		buf = utf8.AppendRune(buf, utf8.RuneError)
+	}
+	return string(buf), nil
+}
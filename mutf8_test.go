@@ -0,0 +1,93 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "errors"
+
+func TestModifiedUTF8(t *testing.T) {
+	cases := []struct {
+		str string
+		enc []byte
+	}{
+		{"abc", []byte("abc")},
+		{"a\x00b", []byte{'a', 0xC0, 0x80, 'b'}},
+		{"é", []byte{0xC3, 0xA9}},
+		{"你好", []byte{0xE4, 0xBD, 0xA0, 0xE5, 0xA5, 0xBD}},
+		{"😀", []byte{0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80}},
+	}
+	for _, c := range cases {
+		if enc := EncodeModifiedUTF8(c.str); !bytes.Equal(enc, c.enc) {
+			t.Fatalf("Encode %q expect %x, but got %x\n", c.str, c.enc, enc)
+		}
+		if ModifiedUTF8Len(c.str) != len(c.enc) {
+			t.Fatalf("ModifiedUTF8Len %q expect %d, but got %d\n", c.str, len(c.enc), ModifiedUTF8Len(c.str))
+		}
+		if dec, err := DecodeModifiedUTF8(c.enc, true); err != nil || dec != c.str {
+			t.Fatalf("Decode %x expect %q, but got %q %v\n", c.enc, c.str, dec, err)
+		}
+	}
+}
+
+func TestModifiedUTF8Malformed(t *testing.T) {
+	cases := []struct {
+		enc     []byte
+		lenient string
+	}{
+		{[]byte{0xF0, 0x9F, 0x98, 0x80}, "����"}, //standard UTF-8 4 bytes form
+		{[]byte{0xED, 0xA0, 0xBD, 'a'}, "�a"},    //unpaired high surrogate
+		{[]byte{0xED, 0xB8, 0x80}, "�"},          //unpaired low surrogate
+		{[]byte{'a', 0xE4, 0xBD}, "a��"},         //truncated
+		{[]byte{0x00}, "\x00"},                   //raw 0x00
+		{[]byte{0xC1, 0x81}, "A"},                //overlong
+	}
+	for _, c := range cases {
+		if _, err := DecodeModifiedUTF8(c.enc, true); err == nil {
+			t.Fatalf("Strict decode %x should fail\n", c.enc)
+		}
+		if dec, err := DecodeModifiedUTF8(c.enc, false); err != nil || dec != c.lenient {
+			t.Fatalf("Lenient decode %x expect %q, but got %q %v\n", c.enc, c.lenient, dec, err)
+		}
+	}
+}
+
+func TestModifiedUTF8Stream(t *testing.T) {
+	jo := NewJavaTcObject(1)
+	clz := NewJavaTcClassDesc("com.david.test.用户", 1, SC_SERIALIZABLE)
+	clz.AddField(NewStringJavaField("名字", "david😀\x00"))
+	jo.AddClassDesc(clz)
+
+	out := new(bytes.Buffer)
+	if err := SerializeJavaEntity(out, jo); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte{0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80, 0xC0, 0x80}) {
+		t.Fatalf("Expect modified UTF-8 in stream %x\n", out.Bytes())
+	}
+	v, err := DeserializeStream(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	mp := v.JsonMap().(map[string]interface{})
+	if mp["名字"] != "david😀\x00" || mp["__class__0"] != "com.david.test.用户" {
+		t.Fatalf("Unexpected json map %v\n", mp)
+	}
+}
+
+func TestModifiedUTF8StrictDecoder(t *testing.T) {
+	for _, bs := range [][]byte{
+		{0xC1, 0x81},             //overlong 'A'
+		{0xF0, 0x9F, 0x98, 0x80}, //raw 4-byte UTF-8
+	} {
+		data := javaStream(TC_STRING, 0x00, byte(len(bs)), bs)
+		var str string
+		if err := NewDecoder(bytes.NewReader(data)).Decode(&str); err != nil {
+			t.Fatalf("Expect lenient decoder accepts % x, but got %v\n", bs, err)
+		}
+		dec := NewDecoder(bytes.NewReader(data))
+		dec.SetStrict(true)
+		var de *DecodeError
+		if err := dec.Decode(&str); !errors.As(err, &de) {
+			t.Fatalf("Expect strict decoder rejects % x, but got %q %v\n", bs, str, err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+
+func TestProxyObject(t *testing.T) {
+	//Proxy.newProxyInstance(loader, new Class[]{Runnable.class}, new Handler())
+	data := javaStream(
+		TC_OBJECT,
+		TC_PROXYCLASSDESC, []byte{0x00, 0x00, 0x00, 0x01}, 0x00, 0x12, "java.lang.Runnable", TC_ENDBLOCKDATA,
+		TC_CLASSDESC, 0x00, 0x17, "java.lang.reflect.Proxy", []byte{0xE1, 0x27, 0xDA, 0x20, 0xCC, 0x10, 0x43, 0xCB}, SC_SERIALIZABLE, 0x00, 0x01,
+		TC_OBJ_OBJECT, 0x00, 0x01, "h", TC_STRING, 0x00, 0x25, "Ljava/lang/reflect/InvocationHandler;",
+		TC_ENDBLOCKDATA, TC_NULL,
+		TC_OBJECT, TC_CLASSDESC, 0x00, 0x07, "Handler", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+	)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	jo := v.(*JavaTcObject)
+	if !jo.IsProxy() || len(jo.ProxyInterfaces()) != 1 || jo.ProxyInterfaces()[0] != "java.lang.Runnable" {
+		t.Fatalf("Unexpected proxy interfaces %v\n", jo.ProxyInterfaces())
+	}
+	handler, ok := jo.InvocationHandler().(*JavaTcObject)
+	if !ok || handler.Classes[0].ClassName != "Handler" {
+		t.Fatalf("Unexpected InvocationHandler %v\n", jo.InvocationHandler())
+	}
+
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, jo); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+	//builder
+	handler = NewJavaTcObject(1)
+	handler.AddClassDesc(NewJavaTcClassDesc("Handler", 1, SC_SERIALIZABLE))
+	out.Reset()
+	if err = SerializeJavaEntity(out, NewJavaProxyObject([]string{"java.lang.Runnable"}, handler)); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
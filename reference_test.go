@@ -0,0 +1,125 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "fmt"
+
+func TestReferencePoolGrow(t *testing.T) {
+	const count = 3000
+	out := new(bytes.Buffer)
+	sw := NewJavaStreamWriter(out)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < count; i++ {
+			if err := sw.WriteEntity(NewJavaTcString(fmt.Sprintf("s%d", i))); err != nil {
+				t.Fatalf("WriteEntity got %v\n", err)
+			}
+		}
+	}
+	sr := NewJavaStreamReader(bytes.NewReader(out.Bytes()))
+	for round := 0; round < 2; round++ {
+		for i := 0; i < count; i++ {
+			content, err := sr.ReadContent()
+			if err != nil {
+				t.Fatalf("ReadContent [%d] got %v\n", i, err)
+			}
+			if expect := JavaTcString(fmt.Sprintf("s%d", i)); content.Entity.JsonMap() != expect {
+				t.Fatalf("Expect %s, but got %v\n", expect, content.Entity.JsonMap())
+			}
+		}
+	}
+	if sr.refs.Len() != count {
+		t.Fatalf("Expect %d handles, but got %d\n", count, sr.refs.Len())
+	}
+}
+
+func TestReferencePoolInvalid(t *testing.T) {
+	pool := NewJavaReferencePool(0)
+	if h := pool.Add(TC_STRING, "a"); h != INTBASE_WIRE_HANDLE {
+		t.Fatalf("Expect first handle 0x%x, but got 0x%x\n", INTBASE_WIRE_HANDLE, h)
+	}
+	if _, err := pool.Get(0); err == nil {
+		t.Fatalf("Expect error for handle below 0x%x\n", INTBASE_WIRE_HANDLE)
+	}
+	if _, err := pool.Get(INTBASE_WIRE_HANDLE + 1); err == nil {
+		t.Fatalf("Expect error for dangling handle\n")
+	}
+	pool.Reset()
+	if _, err := pool.Get(INTBASE_WIRE_HANDLE); err == nil {
+		t.Fatalf("Expect error after reset\n")
+	}
+
+	data := javaStream(TC_STRING, 0x00, 0x01, "a", TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x05})
+	sr := NewJavaStreamReader(bytes.NewReader(data))
+	if _, err := sr.ReadContent(); err != nil {
+		t.Fatalf("ReadContent got %v\n", err)
+	}
+	if _, err := sr.ReadContent(); err == nil {
+		t.Fatalf("Expect error for dangling reference\n")
+	}
+}
+
+func TestReferenceIdentity(t *testing.T) {
+	arr := NewByteArray([]byte{0x01})
+	out := new(bytes.Buffer)
+	sw := NewJavaStreamWriter(out)
+	//equal but distinct arrays are not collapsed, the same pointer is written as TC_REFERENCE
+	for _, a := range []*JavaTcArray{arr, NewByteArray([]byte{0x01}), arr} {
+		if err := sw.WriteEntity(a); err != nil {
+			t.Fatalf("WriteEntity got %v\n", err)
+		}
+	}
+	sr := NewJavaStreamReader(bytes.NewReader(out.Bytes()))
+	entities := make([]JavaSerializer, 3)
+	for i := range entities {
+		content, err := sr.ReadContent()
+		if err != nil {
+			t.Fatalf("ReadContent [%d] got %v\n", i, err)
+		}
+		entities[i] = content.Entity
+	}
+	if entities[0] == entities[1] {
+		t.Fatalf("Expect distinct arrays\n")
+	}
+	if entities[0] != entities[2] {
+		t.Fatalf("Expect the same array by TC_REFERENCE\n")
+	}
+	//classDesc [B, 2 arrays
+	if sr.refs.Len() != 3 {
+		t.Fatalf("Expect 3 handles, but got %d\n", sr.refs.Len())
+	}
+}
+
+func TestReadTcObjectShared(t *testing.T) {
+	//Point p; writeObject(p); writeObject(p); writeObject(new byte[]{1}) twice; writeObject(null)
+	data := javaStream(
+		TC_OBJECT, TC_CLASSDESC, 0x00, 0x05, "Point", []byte{0, 0, 0, 0, 0, 0, 0, 1}, SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x01},
+		TC_ARRAY, TC_CLASSDESC, 0x00, 0x02, "[B", make([]byte, 8), SC_SERIALIZABLE, 0x00, 0x00, TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x00, 0x00, 0x00, 0x01}, 0x01,
+		TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x03},
+		TC_NULL,
+	)
+	reader := bytes.NewReader(data[4:])
+	refs := NewJavaReferencePool(8)
+	first, err := ReadTcObject(reader, refs)
+	if err != nil {
+		t.Fatalf("ReadTcObject got %v\n", err)
+	}
+	if second, err := ReadTcObject(reader, refs); err != nil || second != first {
+		t.Fatalf("Expect the same *JavaTcObject by TC_REFERENCE, but got %p %p %v\n", first, second, err)
+	}
+	arr, err := ReadTcArray(reader, refs)
+	if err != nil {
+		t.Fatalf("ReadTcArray got %v\n", err)
+	}
+	if again, err := ReadTcArray(reader, refs); err != nil || again != arr {
+		t.Fatalf("Expect the same *JavaTcArray by TC_REFERENCE, but got %p %p %v\n", arr, again, err)
+	}
+	if null, err := ReadTcObject(reader, refs); err != nil || null != nil {
+		t.Fatalf("Expect nil for TC_NULL, but got %v %v\n", null, err)
+	}
+	//TC_REFERENCE to the array is not an object
+	if _, err := ReadTcObject(bytes.NewReader([]byte{TC_REFERENCE, 0x00, 0x7E, 0x00, 0x03}), refs); err == nil {
+		t.Fatalf("Expect error for TC_REFERENCE to array\n")
+	}
+}
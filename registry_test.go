@@ -0,0 +1,69 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "io"
+import "reflect"
+
+type registryPoint struct {
+	X int32
+	Y int32
+}
+
+func TestTypeRegistry(t *testing.T) {
+	registry := NewTypeRegistry()
+	names := registry.ClassNames()
+	for _, name := range []string{"java.util.ArrayList", "java.util.HashMap", "java.util.LinkedList"} {
+		if registry.LookupClass(name) == nil {
+			t.Fatalf("Expect built-in %s in %v\n", name, names)
+		}
+	}
+
+	registry.RegisterClass("com.example.Point", &ClassHandler{
+		SerialVersionUID: 1,
+		GoType:           reflect.TypeOf(&registryPoint{}),
+	})
+	//override the built-in ArrayList reader
+	builtin := registry.LookupClass("java.util.ArrayList")
+	var lists int
+	registry.RegisterClass("java.util.ArrayList", &ClassHandler{
+		Read: func(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+			lists++
+			return builtin.Read(reader, refs, classDesc)
+		},
+		Write: builtin.Write,
+	})
+
+	out := new(bytes.Buffer)
+	enc := NewEncoder(out)
+	enc.SetRegistry(registry)
+	p := &registryPoint{X: 1, Y: 2}
+	if err := enc.Encode(p); err != nil {
+		t.Fatalf("Encode got %v\n", err)
+	}
+	if err := enc.Encode(NewArrayList([]interface{}{NewJavaTcString("a")})); err != nil {
+		t.Fatalf("Encode got %v\n", err)
+	}
+
+	dec := NewDecoder(bytes.NewReader(out.Bytes()))
+	dec.SetRegistry(registry)
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("Decode got %v\n", err)
+	}
+	if !reflect.DeepEqual(v, p) {
+		t.Fatalf("Expect %+v, but got %#v\n", p, v)
+	}
+	var lst []string
+	if err := dec.Decode(&lst); err != nil || !reflect.DeepEqual(lst, []string{"a"}) {
+		t.Fatalf("Expect [a], but got %v %v\n", lst, err)
+	}
+	if lists != 1 {
+		t.Fatalf("Expect the overridden ArrayList reader is called once, but got %d\n", lists)
+	}
+
+	registry.RegisterClass("com.example.Point", nil)
+	if err := NewEncoder(new(bytes.Buffer)).Encode(p); err == nil {
+		t.Fatalf("Expect error for Go type without java class name\n")
+	}
+}
@@ -0,0 +1,46 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "reflect"
+
+//class Custom implements Serializable { int x = 7; writeObject: defaultWriteObject, writeInt(0x01020304), writeObject("s"), writeObject(null), writeObject("s") }
+var customRwObject = []interface{}{
+	TC_OBJECT,
+	TC_CLASSDESC, 0x00, 0x06, "Custom", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, SC_RW_OBJECT, 0x00, 0x01,
+	TC_PRIM_INTEGER, 0x00, 0x01, "x",
+	TC_ENDBLOCKDATA, TC_NULL,
+	[]byte{0x00, 0x00, 0x00, 0x07},
+	TC_BLOCKDATA, 0x04, []byte{0x01, 0x02, 0x03, 0x04},
+	TC_STRING, 0x00, 0x01, "s",
+	TC_NULL,
+	TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x02},
+	TC_ENDBLOCKDATA,
+}
+
+func TestRwObjectGeneric(t *testing.T) {
+	data := javaStream(customRwObject...)
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	mp, ok := v.JsonMap().(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expect map, but got %v\n", v.JsonMap())
+	}
+	if mp["x"] != uint32(7) {
+		t.Fatalf("Expect x 7, but got %v\n", mp["x"])
+	}
+	expect := []interface{}{[]byte{0x01, 0x02, 0x03, 0x04}, JavaTcString("s"), nil, JavaTcString("s")}
+	if !reflect.DeepEqual(mp["__annotations__0"], expect) {
+		t.Fatalf("Expect annotations %v, but got %v\n", expect, mp["__annotations__0"])
+	}
+
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, v); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", data, out.Bytes())
+	}
+}
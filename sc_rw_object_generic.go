@@ -0,0 +1,139 @@
+package javaserializer
+
+import "io"
+import "fmt"
+
+// classdata for SC_SERIALIZABLE & SC_WRITE_METHOD:
+// 	wrclass objectAnnotation
+// wrclass:
+// 	nowrclass	// defaultWriteObject 写入的fields
+// objectAnnotation:
+// 	endBlockData
+// 	contents endBlockData
+//
+// 对于没有专门实现的自定义writeObject的类, 读出default fields及objectAnnotation中的所有content,
+// content按原样保存在 JavaTcClassDesc.RwDatas 中, 以便原样序列化回去
+// 	[]byte: 一段block data, *JavaTcString/*JavaTcObject 等: 对象, nil: TC_NULL
+
+//JavaRwObject generic classdata of the class which has its own writeObject
+type JavaRwObject struct {
+	ClassDesc *JavaTcClassDesc
+}
+
+//Deserialize 从classdata部分开始读取, 结果存放在ClassDesc.Fields及ClassDesc.RwDatas中
+func (rw *JavaRwObject) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaRwObject] %s >>\n", rw.ClassDesc.ClassName)
+	defer refs.logger().Debug("[JavaRwObject] %s <<\n", rw.ClassDesc.ClassName)
+
+	//default fields
+	for _, jf := range rw.ClassDesc.Fields {
+		if err := ReadJavaField(jf, reader, refs); err != nil {
+			return err
+		}
+	}
+	//objectAnnotation
+	var err error
+	if rw.ClassDesc.RwDatas, err = ReadObjectAnnotation(reader, refs); err != nil {
+		return err
+	}
+	refs.logger().Debug("[JavaRwObject] got %d annotation contents\n", len(rw.ClassDesc.RwDatas))
+	return nil
+}
+
+//ReadObjectAnnotation read block data & objects until TC_ENDBLOCKDATA, TC_ENDBLOCKDATA is consumed as well
+func ReadObjectAnnotation(reader io.Reader, refs *JavaReferencePool) ([]interface{}, error) {
+	datas := make([]interface{}, 0)
+	for {
+		b, err := ReadNextByte(reader)
+		if err != nil {
+			return nil, err
+		}
+		switch b {
+		case TC_ENDBLOCKDATA:
+			return datas, nil
+		case TC_NULL:
+			datas = append(datas, nil)
+		case TC_BLOCKDATA, TC_BLOCKDATALONG:
+			if data, err := ReadBlockDataContent(b, reader); err != nil {
+				return nil, err
+			} else {
+				datas = append(datas, data)
+			}
+		default:
+			if js, err := ReadEleWithTypeCode(b, reader, refs); err != nil {
+				return nil, err
+			} else {
+				datas = append(datas, js)
+			}
+		}
+	}
+}
+
+//JsonMap return the default field values
+func (rw *JavaRwObject) JsonMap() interface{} {
+	return newJsonVisitor().value(rw, "$")
+}
+
+func (rw *JavaRwObject) jsonMap(v *jsonVisitor, path string) interface{} {
+	return fieldsJsonMap(v, rw.ClassDesc.Fields, path)
+}
+
+//fieldsJsonMap return field name -> json style data of the field value
+func fieldsJsonMap(v *jsonVisitor, fields []*JavaField, path string) map[string]interface{} {
+	mp := make(map[string]interface{})
+	for _, jf := range fields {
+		mp[jf.FieldName] = v.value(jf.FieldValue, path+"."+jf.FieldName)
+	}
+	return mp
+}
+
+//Annotations return json style data of the objectAnnotation contents
+func (rw *JavaRwObject) Annotations() []interface{} {
+	return AnnotationJsonData(rw.ClassDesc.RwDatas)
+}
+
+//AnnotationJsonData return json style data of the contents read by ReadObjectAnnotation
+func AnnotationJsonData(contents []interface{}) []interface{} {
+	return newJsonVisitor().values(contents, "$")
+}
+
+//Serialize write default fields, the objectAnnotation contents and TC_ENDBLOCKDATA
+func (rw *JavaRwObject) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaRwObject] Serialize %s >>\n", rw.ClassDesc.ClassName)
+	defer refs.logger().Debug("[JavaRwObject] Serialize %s <<\n", rw.ClassDesc.ClassName)
+
+	for _, jf := range rw.ClassDesc.Fields {
+		if err := SerializeJavaField(jf, writer, refs); err != nil {
+			return err
+		}
+	}
+	return WriteObjectAnnotation(writer, refs, rw.ClassDesc.RwDatas)
+}
+
+//WriteObjectAnnotation write the contents read by ReadObjectAnnotation and TC_ENDBLOCKDATA
+func WriteObjectAnnotation(writer io.Writer, refs *JavaReferencePool, datas []interface{}) error {
+	var err error
+	for i, item := range datas {
+		switch v := item.(type) {
+		case nil:
+			_, err = writer.Write([]byte{TC_NULL})
+		case []byte:
+			err = WriteBlockDataContent(writer, v)
+		case string:
+			err = NewJavaTcString(v).Serialize(writer, refs)
+		case JavaSerializer:
+			err = v.Serialize(writer, refs)
+		default:
+			err = fmt.Errorf("Unsupport annotation content [%d] %v", i, item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = writer.Write([]byte{TC_ENDBLOCKDATA})
+	return err
+}
@@ -1,131 +1,205 @@
-package main
+package javaserializer
 
 import "io"
 import "fmt"
+import "encoding/binary"
+
+const SID_ARRAY_LIST uint64 = 8683452581122892189
+const SID_LINKED_LIST uint64 = 876323262645176354
 
 //JavaArrayList
+//elements are kept in ClassDesc.RwDatas as well, the same as NewArrayList
 type JavaArrayList struct {
 	Size int
-	Eles []interface{}
+	Eles []interface{} //JavaSerializer, nil for TC_NULL
 }
 
 //Deserialize
-func (arrList *JavaArrayList) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaArrayList] >>\n")
-	defer StdLogger.Debug("[JavaArrayList] <<\n")
+func (arrList *JavaArrayList) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaArrayList] >>\n")
+	defer refs.logger().Debug("[JavaArrayList] <<\n")
 	//start with size
 	if ui, err := ReadUint32(reader); err != nil {
 		return err
+	} else if int32(ui) < 0 {
+		return fmt.Errorf("[JavaArrayList] Illegal size %d", int32(ui))
 	} else {
 		arrList.Size = int(ui)
 	}
-	//TC_BLOCKDATA
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != TC_BLOCKDATA {
-		return fmt.Errorf("There should be TC_BLOCKDATA, but got 0x%x", b)
-	}
-	//should follow by 0x04, 表示4字节后是所有的elements
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != 0x04 {
-		return fmt.Errorf("There should be 0x04, but got 0x%x", b)
-	}
-	if ui, err := ReadUint32(reader); err != nil {
+	//capacity 写在block data中, 与size相同
+	br := NewJavaBlockDataReader(reader)
+	if ui, err := br.ReadInt(); err != nil {
 		return err
 	} else if arrList.Size != int(ui) {
 		return fmt.Errorf("Size should be %d, but got %d", arrList.Size, ui)
 	}
 	//now it's the data
-	arrList.Eles = make([]interface{}, 0, arrList.Size)
-
-	for i := 0; i < arrList.Size; i += 1 {
-
-		if ele, err := ReadNextEle(reader, refs); err != nil {
-			StdLogger.Error("[JavaArrayList] Error when read %d element: %v\n", i, err)
-			return err
-		} else {
-			arrList.Eles = append(arrList.Eles, ele.JsonMap())
-		}
+	if eles, err := readListElements("JavaArrayList", arrList.Size, reader, refs); err != nil {
+		return err
+	} else {
+		arrList.Eles = eles
 	}
 	//TC_ENDBLOCKDATA
 	//must be 0x78 TC_ENDBLOCKDATA
 	if b, err := ReadNextByte(reader); err != nil {
 		return err
 	} else if b != TC_ENDBLOCKDATA {
-		return fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b)
+		return typeCodeError(b, []byte{TC_ENDBLOCKDATA}, fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b))
 	}
 
 	return nil
 }
 
-//JsonMap just return list's elements
+//JsonMap return json style data of list's elements
 func (arrList *JavaArrayList) JsonMap() interface{} {
-	return arrList.Eles
+	return newJsonVisitor().value(arrList, "$")
+}
+
+func (arrList *JavaArrayList) jsonMap(v *jsonVisitor, path string) interface{} {
+	return v.values(arrList.Eles, path)
+}
+
+//readListElements read size elements of the list, the path of element i is [i]
+func readListElements(name string, size int, reader io.Reader, refs *JavaReferencePool) ([]interface{}, error) {
+	eles := make([]interface{}, 0, min(size, MAX_PREALLOC_ELEMENTS))
+	refs.pushIndex()
+	defer refs.popPath()
+	for i := 0; i < size; i += 1 {
+		refs.setIndex(i)
+		if ele, err := ReadNextEle(reader, refs); err != nil {
+			refs.logger().Error("[%s] Error when read %d element: %v\n", name, i, err)
+			return nil, refs.decodeError(err)
+		} else {
+			eles = append(eles, ele)
+		}
+	}
+	return eles, nil
 }
 
 //JavaLinkedList
 type JavaLinkedList struct {
 	Size int
-	Eles []interface{}
+	Eles []interface{} //JavaSerializer, nil for TC_NULL
 }
 
 //Deserialize
-func (linkedList *JavaLinkedList) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaLinkedList] >>\n")
-	defer StdLogger.Debug("[JavaLinkedList] <<\n")
-	//TC_BLOCKDATA
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != TC_BLOCKDATA {
-		return fmt.Errorf("There should be TC_BLOCKDATA, but got 0x%x", b)
-	}
-	//should follow by 0x04, 表示4字节后是所有的elements
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != 0x04 {
-		return fmt.Errorf("There should be 0x04, but got 0x%x", b)
-	}
-	if ui, err := ReadUint32(reader); err != nil {
+func (linkedList *JavaLinkedList) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaLinkedList] >>\n")
+	defer refs.logger().Debug("[JavaLinkedList] <<\n")
+	//size 写在block data中
+	br := NewJavaBlockDataReader(reader)
+	if ui, err := br.ReadInt(); err != nil {
 		return err
+	} else if ui < 0 {
+		return fmt.Errorf("[JavaLinkedList] Illegal size %d", ui)
 	} else {
 		linkedList.Size = int(ui)
 	}
 	//now it's the data
-	linkedList.Eles = make([]interface{}, 0, linkedList.Size)
-
-	for i := 0; i < linkedList.Size; i += 1 {
-
-		if ele, err := ReadNextEle(reader, refs); err != nil {
-			StdLogger.Error("[JavaLinkedList] Error when read %d element: %v\n", i, err)
-			return err
-		} else {
-			linkedList.Eles = append(linkedList.Eles, ele.JsonMap())
-		}
+	if eles, err := readListElements("JavaLinkedList", linkedList.Size, reader, refs); err != nil {
+		return err
+	} else {
+		linkedList.Eles = eles
 	}
 	//TC_ENDBLOCKDATA
 	//must be 0x78 TC_ENDBLOCKDATA
 	if b, err := ReadNextByte(reader); err != nil {
 		return err
 	} else if b != TC_ENDBLOCKDATA {
-		return fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b)
+		return typeCodeError(b, []byte{TC_ENDBLOCKDATA}, fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b))
 	}
 
 	return nil
 }
 
-//JsonMap just return list's elements
+//JsonMap return json style data of list's elements
 func (linkedList *JavaLinkedList) JsonMap() interface{} {
-	return linkedList.Eles
+	return newJsonVisitor().value(linkedList, "$")
 }
 
-func (linkedList *JavaLinkedList) Serialize(writer io.Writer, refs []*JavaReferenceObject) error {
-	return fmt.Errorf("to be continued....")
+func (linkedList *JavaLinkedList) jsonMap(v *jsonVisitor, path string) interface{} {
+	return v.values(linkedList.Eles, path)
 }
-func (arrayList *JavaArrayList) Serialize(writer io.Writer, refs []*JavaReferenceObject) error {
-	return fmt.Errorf("to be continued....")
+
+//Serialize write size in block data, the elements and TC_ENDBLOCKDATA
+func (linkedList *JavaLinkedList) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaLinkedList] Serialize >>\n")
+	defer refs.logger().Debug("[JavaLinkedList] Serialize <<\n")
+	bw := NewJavaBlockDataWriter(writer)
+	if err := bw.WriteInt(int32(len(linkedList.Eles))); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return serializeListElements(writer, refs, linkedList.Eles)
+}
+
+//Serialize write field size, capacity in block data, the elements and TC_ENDBLOCKDATA
+func (arrayList *JavaArrayList) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaArrayList] Serialize >>\n")
+	defer refs.logger().Debug("[JavaArrayList] Serialize <<\n")
+	size := len(arrayList.Eles)
+	buff := make([]byte, 4)
+	binary.BigEndian.PutUint32(buff, uint32(size))
+	if _, err := writer.Write(buff); err != nil {
+		return err
+	}
+	bw := NewJavaBlockDataWriter(writer)
+	if err := bw.WriteInt(int32(size)); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return serializeListElements(writer, refs, arrayList.Eles)
+}
+
+//serializeListElements write the elements and TC_ENDBLOCKDATA
+func serializeListElements(writer io.Writer, refs *JavaReferencePool, eles []interface{}) error {
+	var err error
+	for i, ele := range eles {
+		switch v := ele.(type) {
+		case nil:
+			_, err = writer.Write([]byte{TC_NULL})
+		case string:
+			err = NewJavaTcString(v).Serialize(writer, refs)
+		case JavaSerializer:
+			err = v.Serialize(writer, refs)
+		default:
+			err = fmt.Errorf("Unsupport list element [%d] %v", i, ele)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err = writer.Write([]byte{TC_ENDBLOCKDATA})
+	return err
+}
+
+//NewArrayList new java.util.ArrayList, the elements are JavaSerializer, string or nil
+func NewArrayList(eles []interface{}) *JavaTcObject {
+	clzDesc := NewJavaTcClassDesc("java.util.ArrayList", SID_ARRAY_LIST, SC_RW_OBJECT)
+	clzDesc.AddField(NewJavaField(TC_PRIM_INTEGER, "size", uint32(len(eles))))
+	clzDesc.RwDatas = eles
+	jo := NewJavaTcObject(SID_ARRAY_LIST)
+	jo.AddClassDesc(clzDesc)
+	return jo
+}
+
+//NewLinkedList new java.util.LinkedList, the elements are JavaSerializer, string or nil
+func NewLinkedList(eles []interface{}) *JavaTcObject {
+	clzDesc := NewJavaTcClassDesc("java.util.LinkedList", SID_LINKED_LIST, SC_RW_OBJECT)
+	clzDesc.RwDatas = eles
+	jo := NewJavaTcObject(SID_LINKED_LIST)
+	jo.AddClassDesc(clzDesc)
+	return jo
 }
@@ -1,4 +1,4 @@
-package main
+package javaserializer
 
 import "io"
 import "math"
@@ -10,12 +10,12 @@ const SID_HASH_MAP = 362498820763181265
 const SID_LINKED_HASH_MAP = 3801124242820219131
 
 //JavaHashMap
+//entries are kept in ClassDesc.RwDatas as key, value, key, value..., the same as NewHashMap
 type JavaHashMap struct {
 	ClassDesc  *JavaTcClassDesc
 	LoadFactor float32
 	Thredshold uint32
 	Buckets    uint32
-	Entries    map[string]interface{} //golang json unmashall does not support interface{} type as it's key
 }
 
 //GenerateHashMapClassDesc
@@ -62,31 +62,37 @@ func GenerateHashMapFields(size int) []*JavaField {
 	return jfs
 }
 
-//NewHashMap new hash map
-func NewHashMap(mp map[string]interface{}) *JavaTcObject {
-	items := MapData2Slice(mp)
+//NewHashMap new hash map, error if the value is not supported by MapData2Slice
+func NewHashMap(mp map[string]interface{}) (*JavaTcObject, error) {
+	items, err := MapData2Slice(mp)
+	if err != nil {
+		return nil, err
+	}
 	clzDesc := GenerateHashMapClassDesc(items)
 	jo := NewJavaTcObject(SID_HASH_MAP)
 	jo.AddClassDesc(clzDesc)
-	return jo
+	return jo, nil
 }
 
-//NewLinkedHashMap new hash map
-func NewLinkedHashMap(mp map[string]interface{}) *JavaTcObject {
-	items := MapData2Slice(mp)
+//NewLinkedHashMap new hash map, error if the value is not supported by MapData2Slice
+func NewLinkedHashMap(mp map[string]interface{}) (*JavaTcObject, error) {
+	items, err := MapData2Slice(mp)
+	if err != nil {
+		return nil, err
+	}
 	clzDesc := GenerateHashMapClassDesc(items)
 	jo := NewJavaTcObject(SID_LINKED_HASH_MAP)
 	jo.AddClassDesc(GenerateLinkedHashMapClassDesc())
 	jo.AddClassDesc(clzDesc)
-	return jo
+	return jo, nil
 }
 
 //Deserialize 从classdata部分开始读取
-func (mp *JavaHashMap) Deserialize(reader io.Reader, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaHashMap] >>\n")
-	defer StdLogger.Debug("[JavaHashMap] <<\n")
+func (mp *JavaHashMap) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaHashMap] >>\n")
+	defer refs.logger().Debug("[JavaHashMap] <<\n")
 
 	//loadFactor
 	if lf, err := ReadUint32(reader); err != nil {
@@ -101,70 +107,86 @@ func (mp *JavaHashMap) Deserialize(reader io.Reader, refs []*JavaReferenceObject
 		mp.Thredshold = ts
 	}
 
-	//must be 0x77 TC_BLOCKDATA
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != TC_BLOCKDATA {
-		return fmt.Errorf("There should be TC_BLOCKDATA, but got 0x%x", b)
-	}
-	//should follow by 0x08, 表示8字节后是所有的Entry
-	if b, err := ReadNextByte(reader); err != nil {
-		return err
-	} else if b != 0x08 {
-		return fmt.Errorf("There should be 0x08, but got 0x%x", b)
-	}
-
-	if bt, err := ReadUint32(reader); err != nil {
+	//buckets & size 写在block data中, 之后是所有的Entry
+	br := NewJavaBlockDataReader(reader)
+	if bt, err := br.ReadInt(); err != nil {
 		return err
 	} else {
-		mp.Buckets = bt
-		StdLogger.Debug("[JavaHashMap] has %d buckest\n", bt)
+		mp.Buckets = uint32(bt)
+		refs.logger().Debug("[JavaHashMap] has %d buckest\n", bt)
 	}
 	//size
 	var size int
-	if sz, err := ReadUint32(reader); err != nil {
+	if sz, err := br.ReadInt(); err != nil {
 		return err
+	} else if sz < 0 {
+		return fmt.Errorf("[JavaHashMap] Illegal size %d", sz)
 	} else {
 		size = int(sz)
 	}
-	StdLogger.Debug("[JavaHashMap] has %d entries\n", size)
-	mp.Entries = make(map[string]interface{})
-
-	for i := 0; i < size; i += 1 {
-		StdLogger.Debug("[JavaHashMap] try to read entry [%d]\n", i)
-		if k, err := ReadNextEle(reader, refs); err != nil {
-			StdLogger.Error("[JavaHashMap] Error when read %d entry's key: %v\n", i, err)
-			return err
-		} else if v, err := ReadNextEle(reader, refs); err != nil {
-			StdLogger.Error("[JavaHashMap] Error when read %d entry's value: %v\n", i, err)
-			return err
-		} else {
-			StdLogger.Debug("[JavaHashMap] Got Entry [%d] %v <-> %v\n", i, k, v)
-			mp.Entries[fmt.Sprintf("%v", k.JsonMap())] = v.JsonMap()
-		}
-
+	if br.Remain() != 0 {
+		return fmt.Errorf("[JavaHashMap] Unexpected %d bytes left in block data", br.Remain())
+	}
+	refs.logger().Debug("[JavaHashMap] has %d entries\n", size)
+	if datas, err := readMapEntries(size, reader, refs); err != nil {
+		return err
+	} else {
+		mp.ClassDesc.RwDatas = datas
 	}
 	//
 	//must be 0x78 TC_ENDBLOCKDATA
 	if b, err := ReadNextByte(reader); err != nil {
 		return err
 	} else if b != TC_ENDBLOCKDATA {
-		return fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b)
+		return typeCodeError(b, []byte{TC_ENDBLOCKDATA}, fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b))
 	}
 
 	return nil
 }
 
+//readMapEntries read size entries as key, value, key, value..., the path of entry i is [i]
+func readMapEntries(size int, reader io.Reader, refs *JavaReferencePool) ([]interface{}, error) {
+	datas := make([]interface{}, 0, min(size, MAX_PREALLOC_ELEMENTS)*2)
+	refs.pushIndex()
+	defer refs.popPath()
+	for i := 0; i < size; i += 1 {
+		refs.logger().Debug("[JavaHashMap] try to read entry [%d]\n", i)
+		refs.setIndex(i)
+		if k, err := ReadNextEle(reader, refs); err != nil {
+			refs.logger().Error("[JavaHashMap] Error when read %d entry's key: %v\n", i, err)
+			return nil, refs.decodeError(err)
+		} else if v, err := ReadNextEle(reader, refs); err != nil {
+			refs.logger().Error("[JavaHashMap] Error when read %d entry's value: %v\n", i, err)
+			return nil, refs.decodeError(err)
+		} else {
+			refs.logger().Debug("[JavaHashMap] Got Entry [%d] %v <-> %v\n", i, k, v)
+			datas = append(datas, k, v)
+		}
+	}
+	return datas, nil
+}
+
 //JsonMap return json style data
+//golang json unmashall does not support interface{} type as it's key, so the keys are formatted as string
 func (mp *JavaHashMap) JsonMap() interface{} {
-	return mp.Entries
+	return newJsonVisitor().value(mp, "$")
 }
 
-func (mp *JavaHashMap) Serialize(writer io.Writer, refs []*JavaReferenceObject) error {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[JavaHashMap] Serialize >>\n")
-	defer StdLogger.Debug("[JavaHashMap] Serialize <<\n")
+func (mp *JavaHashMap) jsonMap(v *jsonVisitor, path string) interface{} {
+	entries := make(map[string]interface{})
+	datas := mp.ClassDesc.RwDatas
+	for i := 0; i+1 < len(datas); i += 2 {
+		key := fmt.Sprintf("%v", v.value(datas[i], path))
+		entries[key] = v.value(datas[i+1], path+"."+key)
+	}
+	return entries
+}
+
+func (mp *JavaHashMap) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaHashMap] Serialize >>\n")
+	defer refs.logger().Debug("[JavaHashMap] Serialize <<\n")
 
 	buff := make([]byte, 8)
 	var err error
@@ -184,26 +206,21 @@ func (mp *JavaHashMap) Serialize(writer io.Writer, refs []*JavaReferenceObject)
 		return err
 	}
 
-	buff[0] = TC_BLOCKDATA
-	buff[1] = 0x08
-	if _, err = writer.Write(buff[:2]); err != nil {
+	//buckets & entries count
+	bw := NewJavaBlockDataWriter(writer)
+	if err = bw.WriteInt(int32(ui32)); err != nil {
 		return err
 	}
-	//buckets
-	binary.BigEndian.PutUint32(buff[:4], ui32)
-	if _, err = writer.Write(buff[:4]); err != nil {
+	if err = bw.WriteInt(int32(len(datas) / 2)); err != nil {
 		return err
 	}
-	//entryies count
-	ui32 = uint32(len(datas) / 2)
-	binary.BigEndian.PutUint32(buff[:4], ui32)
-	if _, err = writer.Write(buff[:4]); err != nil {
+	if err = bw.Flush(); err != nil {
 		return err
 	}
 
 	for i := 0; i < len(datas); i += 1 {
 		var item interface{} = datas[i]
-		//StdLogger.Warn("Got item %d %v\n", i, item)
+		//refs.logger().Warn("Got item %d %v\n", i, item)
 		if str, ok := item.(string); ok {
 			tcStr := new(JavaTcString)
 			*tcStr = (JavaTcString)(str)
@@ -222,6 +239,15 @@ func (mp *JavaHashMap) Serialize(writer io.Writer, refs []*JavaReferenceObject)
 			if err = jArr.Serialize(writer, refs); err != nil {
 				return err
 			}
+		} else if js, ok := item.(JavaSerializer); ok {
+			if err = js.Serialize(writer, refs); err != nil {
+				return err
+			}
+		} else if item == nil {
+			buff[0] = TC_NULL
+			if _, err = writer.Write(buff[:1]); err != nil {
+				return err
+			}
 		} else {
 			return fmt.Errorf("Unsupport map entry type %v", item)
 		}
@@ -233,42 +259,40 @@ func (mp *JavaHashMap) Serialize(writer io.Writer, refs []*JavaReferenceObject)
 	return err
 }
 
-//MapData2Slice wrap map to slice
-func MapData2Slice(mp map[string]interface{}) []interface{} {
+//MapData2Slice wrap map to key, value, key, value... slice
+//the slice values should be []byte or []string, error for the other slices
+func MapData2Slice(mp map[string]interface{}) ([]interface{}, error) {
 
 	slade := make([]interface{}, 0, len(mp)*2)
 	for k, v := range mp {
 		slade = append(slade, k)
 		tv := reflect.TypeOf(v)
-		if tv.Kind() != reflect.Slice {
+		if tv == nil || tv.Kind() != reflect.Slice {
 			slade = append(slade, v)
 		} else {
 			te := tv.Elem().Kind()
 			switch te {
 			case reflect.Uint8: //表示字节流数组
 				if tmpArr, ok := v.([]byte); !ok {
-					StdLogger.Error("Expect []byte for key %s, but got %v\n", k, v)
-					return nil
+					return nil, fmt.Errorf("Expect []byte for key %s, but got %T", k, v)
 				} else {
 					jArr := NewByteArray(tmpArr)
 					slade = append(slade, jArr)
 				}
 			case reflect.String:
 				if tmpArr, ok := v.([]string); !ok {
-					StdLogger.Error("Expect []byte for key %s, but got %v\n", k, v)
-					return nil
+					return nil, fmt.Errorf("Expect []string for key %s, but got %T", k, v)
 				} else {
 					jArr := NewStringArray(tmpArr)
 					slade = append(slade, jArr)
 				}
 
 			default:
-				StdLogger.Error("Unsupport hashmap value type %s for %v\n", te, v)
-				return nil
+				return nil, fmt.Errorf("Unsupport hashmap value type %s for key %s", te, k)
 			}
 		}
 
 	}
 
-	return slade
+	return slade, nil
 }
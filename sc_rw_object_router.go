@@ -1,4 +1,4 @@
-package main
+package javaserializer
 
 import "io"
 import "fmt"
@@ -9,132 +9,216 @@ import "fmt"
 //DeserializeScRwObject
 //反序列化 SC_FLAG为 SC_RW_OBJECT 0x03的
 //我们从0x78, 0x70 之后真正开始数据的地方读取
-func DeserializeScRwObject(reader io.Reader, refs []*JavaReferenceObject, className string) (JavaSerializer, error) {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[DeserializeScRwObject] >>\n")
-	defer StdLogger.Debug("[DeserializeScRwObject] <<\n")
-	//
-	switch className {
-	case "java.util.HashMap", "java.util.LinkedHashMap":
-		mp := &JavaHashMap{}
-		if err := mp.Deserialize(reader, refs); err != nil {
-			return nil, err
-		} else {
+//按registry中注册的ClassReader读取, 没有注册的读出default fields及objectAnnotation
+func DeserializeScRwObject(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+	className := classDesc.ClassName
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[DeserializeScRwObject] >>\n")
+	defer refs.logger().Debug("[DeserializeScRwObject] <<\n")
+	if handler := refs.registry().LookupClass(className); handler != nil && handler.Read != nil {
+		return handler.Read(reader, refs, classDesc)
+	}
+	//没有专门实现的类, 读出default fields及objectAnnotation
+	refs.logger().Debug("[DeserializeScRwObject] generic classdata for %s\n", className)
+	rw := &JavaRwObject{
+		ClassDesc: classDesc,
+	}
+	if err := rw.Deserialize(reader, refs); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+//builtinClassHandlers the handlers every TypeRegistry starts with
+func builtinClassHandlers() map[string]*ClassHandler {
+	hashMap := &ClassHandler{
+		Read: func(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+			mp := &JavaHashMap{
+				ClassDesc: classDesc,
+			}
+			if err := mp.Deserialize(reader, refs); err != nil {
+				return nil, err
+			}
 			return mp, nil
-		}
-	case "java.util.ArrayList":
-		lst := &JavaArrayList{}
-		if err := lst.Deserialize(reader, refs); err != nil {
-			return nil, err
-		} else {
-			return lst, nil
-		}
-	case "java.util.LinkedList":
-		lst := &JavaLinkedList{}
-		if err := lst.Deserialize(reader, refs); err != nil {
-			return nil, err
-		} else {
-			return lst, nil
-		}
-	default:
-		return nil, fmt.Errorf("[DeserializeScRwObject] unexpected className %s, not be supported", className)
+		},
+		Write: func(writer io.Writer, refs *JavaReferencePool, classDesc *JavaTcClassDesc) error {
+			mp := &JavaHashMap{
+				ClassDesc: classDesc,
+			}
+			return mp.Serialize(writer, refs)
+		},
+	}
+	return map[string]*ClassHandler{
+		"java.util.HashMap":       hashMap,
+		"java.util.LinkedHashMap": hashMap,
+		"java.util.ArrayList": {
+			SerialVersionUID: SID_ARRAY_LIST,
+			Read: func(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+				lst := &JavaArrayList{}
+				if err := lst.Deserialize(reader, refs); err != nil {
+					return nil, err
+				}
+				classDesc.RwDatas = lst.Eles
+				return lst, nil
+			},
+			Write: func(writer io.Writer, refs *JavaReferencePool, classDesc *JavaTcClassDesc) error {
+				lst := &JavaArrayList{
+					Size: len(classDesc.RwDatas),
+					Eles: classDesc.RwDatas,
+				}
+				return lst.Serialize(writer, refs)
+			},
+		},
+		"java.util.LinkedList": {
+			SerialVersionUID: SID_LINKED_LIST,
+			Read: func(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+				lst := &JavaLinkedList{}
+				if err := lst.Deserialize(reader, refs); err != nil {
+					return nil, err
+				}
+				classDesc.RwDatas = lst.Eles
+				return lst, nil
+			},
+			Write: func(writer io.Writer, refs *JavaReferencePool, classDesc *JavaTcClassDesc) error {
+				lst := &JavaLinkedList{
+					Size: len(classDesc.RwDatas),
+					Eles: classDesc.RwDatas,
+				}
+				return lst.Serialize(writer, refs)
+			},
+		},
+		"java.lang.Throwable": {
+			Read: func(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error) {
+				th := &JavaThrowable{
+					ClassDesc: classDesc,
+				}
+				if err := th.Deserialize(reader, refs); err != nil {
+					return nil, err
+				}
+				return th, nil
+			},
+			Write: func(writer io.Writer, refs *JavaReferencePool, classDesc *JavaTcClassDesc) error {
+				th := &JavaThrowable{
+					ClassDesc: classDesc,
+				}
+				return th.Serialize(writer, refs)
+			},
+		},
 	}
 }
 
 //ReadNextEle
 //read next map entry or list element
-func ReadNextEle(reader io.Reader, refs []*JavaReferenceObject) (JavaSerializer, error) {
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[ReadNextEle] >>\n")
-	defer StdLogger.Debug("[ReadNextEle] <<\n")
-	var tp byte //type
-	var err error
-
-	if tp, err = ReadNextByte(reader); err != nil {
+func ReadNextEle(reader io.Reader, refs *JavaReferencePool) (JavaSerializer, error) {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[ReadNextEle] >>\n")
+	defer refs.logger().Debug("[ReadNextEle] <<\n")
+	if tp, err := ReadNextByte(reader); err != nil {
 		return nil, err
+	} else {
+		return ReadEleWithTypeCode(tp, reader, refs)
 	}
-	StdLogger.Debug("[ReadNextEle] type is 0x%x\n", tp)
-	var js JavaSerializer
+}
+
+//ReadEleWithTypeCode
+//read element whose type code tp has been consumed already, nil for TC_NULL
+//TC_REFERENCE returns the same JavaSerializer as the referenced one
+func ReadEleWithTypeCode(tp byte, reader io.Reader, refs *JavaReferencePool) (JavaSerializer, error) {
+	refs.logger().Debug("[ReadNextEle] type is 0x%x\n", tp)
 	switch tp {
-	case TC_STRING:
-		js = new(JavaTcString)
-	case TC_ARRAY:
-		js = &JavaTcArray{}
-	case TC_OBJECT:
-		js = &JavaTcObject{}
+	case TC_NULL:
+		return nil, nil
+	case TC_EXCEPTION:
+		return nil, ReadJavaException(reader, refs)
+	case TC_RESET:
+		//java只允许在最外层reset, 对象内部出现的TC_RESET视为错误
+		return nil, fmt.Errorf("Unexpected TC_RESET inside object graph")
+	case TC_STRING, TC_LONGSTRING:
+		if str, err := ReadTcStringContent(tp, reader, refs); err != nil {
+			return nil, err
+		} else {
+			return NewJavaTcString(str), nil
+		}
+	case TC_ARRAY, TC_OBJECT, TC_ENUM, TC_CLASS:
+		//tag 已被消费, 下一个字节为classDesc, 其TC_REFERENCE不能与对象的TC_REFERENCE混淆
+		b, err := ReadNextByte(reader)
+		if err != nil {
+			return nil, err
+		}
+		switch tp {
+		case TC_ARRAY:
+			tcArr := &JavaTcArray{}
+			if err = tcArr.deserializeBody(b, reader, refs); err != nil {
+				return nil, err
+			}
+			return tcArr, nil
+		case TC_ENUM:
+			tcEnum := &JavaTcEnum{}
+			if err = tcEnum.deserializeBody(b, reader, refs); err != nil {
+				return nil, err
+			}
+			return tcEnum, nil
+		case TC_CLASS:
+			tcClass := &JavaTcClass{}
+			if err = tcClass.deserializeBody(b, reader, refs); err != nil {
+				return nil, err
+			}
+			return tcClass, nil
+		}
+		jo := &JavaTcObject{}
+		if err = jo.deserializeBody(b, reader, refs); err != nil {
+			return nil, err
+		}
+		return jo, nil
 	case TC_REFERENCE:
 		if refIndex, err := ReadUint32(reader); err != nil {
 			return nil, err
+		} else if ref, err := refs.Get(refIndex); err != nil {
+			return nil, err
 		} else {
-			ref := refs[refIndex-INTBASE_WIRE_HANDLE]
 			switch ref.RefType {
 			case TC_STRING:
 				if str, ok := ref.Val.(string); !ok {
-					return nil, fmt.Errorf("[JavaHashMap] ref [%v] value should be string type", ref.Val)
+					return nil, fmt.Errorf("%w: [JavaHashMap] ref [%v] value should be string type", ErrBadReference, ref.Val)
 				} else {
 					tcStr := new(JavaTcString)
 					*tcStr = JavaTcString(str)
 					return tcStr, nil
 				}
-			case TC_ARRAY, TC_OBJECT:
+			case TC_ARRAY, TC_OBJECT, TC_ENUM, TC_CLASS:
 				if tempJs, ok := ref.Val.(JavaSerializer); !ok {
-					return nil, fmt.Errorf("[JavaHashMap] ref [%v] value should be JavaSerializer type", ref.Val)
+					return nil, fmt.Errorf("%w: [JavaHashMap] ref [%v] value should be JavaSerializer type", ErrBadReference, ref.Val)
 				} else {
 					return tempJs, nil
 				}
 			default:
-				return nil, fmt.Errorf("[JavaHashMap] unexpected refType 0x%x", ref.RefType)
+				return nil, fmt.Errorf("%w: [JavaHashMap] unexpected refType 0x%x", ErrBadReference, ref.RefType)
 
 			}
 
 		}
 	default:
-		return nil, fmt.Errorf("Unexpected type 0x%x for map entry", tp)
-	}
-	if err = js.Deserialize(reader, refs); err != nil {
-		return nil, err
+		return nil, typeCodeError(tp, []byte{TC_NULL, TC_REFERENCE, TC_STRING, TC_LONGSTRING, TC_ARRAY, TC_OBJECT, TC_ENUM, TC_CLASS, TC_EXCEPTION}, fmt.Errorf("Unexpected type 0x%x for map entry", tp))
 	}
-	return js, nil
-
 }
 
 //SerializeScRwObject
 //序列化 SC_FLAG为 SC_RW_OBJECT 0x03的
 //我们从0x78, 0x70 之后真正开始数据的地方写入
-func SerializeScRwObject(writer io.Writer, refs []*JavaReferenceObject, classDesc *JavaTcClassDesc) error {
+//按registry中注册的ClassWriter写入, 没有注册的写出default fields及objectAnnotation
+func SerializeScRwObject(writer io.Writer, refs *JavaReferencePool, classDesc *JavaTcClassDesc) error {
 	className := classDesc.ClassName
-	StdLogger.LevelUp()
-	defer StdLogger.LevelDown()
-	StdLogger.Debug("[SerializeScRwObject] >>\n")
-	defer StdLogger.Debug("[SerializeScRwObject] <<\n")
-	//
-	switch className {
-	case "java.util.HashMap", "java.util.LinkedHashMap":
-		mp := &JavaHashMap{
-			ClassDesc: classDesc,
-		}
-		if err := mp.Serialize(writer, refs); err != nil {
-			return err
-		} else {
-			return nil
-		}
-	case "java.util.ArrayList":
-		lst := &JavaArrayList{}
-		if err := lst.Serialize(writer, refs); err != nil {
-			return err
-		} else {
-			return nil
-		}
-	case "java.util.LinkedList":
-		lst := &JavaLinkedList{}
-		if err := lst.Serialize(writer, refs); err != nil {
-			return err
-		} else {
-			return nil
-		}
-	default:
-		return fmt.Errorf("[SerializeScRwObject] unexpected className %s, not be supported", className)
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[SerializeScRwObject] >>\n")
+	defer refs.logger().Debug("[SerializeScRwObject] <<\n")
+	if handler := refs.registry().LookupClass(className); handler != nil && handler.Write != nil {
+		return handler.Write(writer, refs, classDesc)
+	}
+	rw := &JavaRwObject{
+		ClassDesc: classDesc,
 	}
+	return rw.Serialize(writer, refs)
 }
@@ -0,0 +1,57 @@
+package javaserializer
+
+import "io"
+import "fmt"
+
+const SID_THROWABLE uint64 = 0xD5C635273977B8CB //java.lang.Throwable, -3042686055658047285L
+
+//JavaThrowable java.lang.Throwable's classdata
+//Throwable.writeObject 只调用了defaultWriteObject, 所以是default fields 加 TC_ENDBLOCKDATA
+type JavaThrowable struct {
+	ClassDesc *JavaTcClassDesc
+}
+
+//Deserialize 从classdata部分开始读取
+func (th *JavaThrowable) Deserialize(reader io.Reader, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaThrowable] >>\n")
+	defer refs.logger().Debug("[JavaThrowable] <<\n")
+
+	for _, jf := range th.ClassDesc.Fields {
+		if err := ReadJavaField(jf, reader, refs); err != nil {
+			return err
+		}
+	}
+	//must be 0x78 TC_ENDBLOCKDATA
+	if b, err := ReadNextByte(reader); err != nil {
+		return err
+	} else if b != TC_ENDBLOCKDATA {
+		return typeCodeError(b, []byte{TC_ENDBLOCKDATA}, fmt.Errorf("There should be TC_ENDBLOCKDATA, but got 0x%x", b))
+	}
+	return nil
+}
+
+//JsonMap return field values, the cause of a throwable without cause is itself
+func (th *JavaThrowable) JsonMap() interface{} {
+	return newJsonVisitor().value(th, "$")
+}
+
+func (th *JavaThrowable) jsonMap(v *jsonVisitor, path string) interface{} {
+	return fieldsJsonMap(v, th.ClassDesc.Fields, path)
+}
+
+//Serialize write default fields and TC_ENDBLOCKDATA
+func (th *JavaThrowable) Serialize(writer io.Writer, refs *JavaReferencePool) error {
+	refs.logger().LevelUp()
+	defer refs.logger().LevelDown()
+	refs.logger().Debug("[JavaThrowable] Serialize >>\n")
+	defer refs.logger().Debug("[JavaThrowable] Serialize <<\n")
+	for _, jf := range th.ClassDesc.Fields {
+		if err := SerializeJavaField(jf, writer, refs); err != nil {
+			return err
+		}
+	}
+	_, err := writer.Write([]byte{TC_ENDBLOCKDATA})
+	return err
+}
@@ -0,0 +1,116 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "io"
+import "reflect"
+import "errors"
+import "testing/iotest"
+
+func TestStreamWriterReset(t *testing.T) {
+	out := new(bytes.Buffer)
+	sw := NewJavaStreamWriter(out)
+	if err := sw.WriteEntity(NewJavaTcString("a")); err != nil {
+		t.Fatalf("WriteEntity got %v\n", err)
+	}
+	if err := sw.WriteEntity(NewJavaTcString("a")); err != nil {
+		t.Fatalf("WriteEntity got %v\n", err)
+	}
+	if err := sw.Reset(); err != nil {
+		t.Fatalf("Reset got %v\n", err)
+	}
+	if err := sw.WriteEntity(NewJavaTcString("a")); err != nil {
+		t.Fatalf("WriteEntity got %v\n", err)
+	}
+	expect := javaStream(TC_STRING, 0x00, 0x01, "a", TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x00}, TC_RESET, TC_STRING, 0x00, 0x01, "a")
+	if !bytes.Equal(out.Bytes(), expect) {
+		t.Fatalf("Expect\n%x\nbut got\n%x\n", expect, out.Bytes())
+	}
+}
+
+func TestStreamReset(t *testing.T) {
+	data := javaStream(TC_RESET, TC_RESET, TC_STRING, 0x00, 0x01, "a")
+	if v, err := DeserializeStream(bytes.NewReader(data)); err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	} else if v.JsonMap() != JavaTcString("a") {
+		t.Fatalf("Expect a, but got %v\n", v.JsonMap())
+	}
+}
+
+func TestStreamReader(t *testing.T) {
+	//oos.writeObject("a"); oos.writeObject(null); oos.writeInt(5); oos.writeObject("a"); oos.writeObject(Color.RED)
+	parts := []interface{}{
+		TC_STRING, 0x00, 0x01, "a",
+		TC_NULL,
+		TC_BLOCKDATA, 0x04, []byte{0x00, 0x00, 0x00, 0x05},
+		TC_REFERENCE, []byte{0x00, 0x7E, 0x00, 0x00},
+	}
+	data := javaStream(append(parts, enumColorRed...)...)
+	sr := NewJavaStreamReader(bytes.NewReader(data))
+	expects := []byte{TC_STRING, TC_NULL, TC_BLOCKDATA, TC_STRING, TC_ENUM}
+	for i, kind := range expects {
+		content, err := sr.ReadContent()
+		if err != nil {
+			t.Fatalf("ReadContent [%d] got %v\n", i, err)
+		}
+		if content.Kind != kind {
+			t.Fatalf("ReadContent [%d] expect kind 0x%x, but got 0x%x\n", i, kind, content.Kind)
+		}
+		switch kind {
+		case TC_STRING:
+			if content.Entity.JsonMap() != JavaTcString("a") {
+				t.Fatalf("ReadContent [%d] expect a, but got %v\n", i, content.Entity.JsonMap())
+			}
+		case TC_BLOCKDATA:
+			if !bytes.Equal(content.Data, []byte{0x00, 0x00, 0x00, 0x05}) {
+				t.Fatalf("ReadContent [%d] unexpected block data %x\n", i, content.Data)
+			}
+		case TC_ENUM:
+			if content.Entity.JsonMap() != "RED" {
+				t.Fatalf("ReadContent [%d] expect RED, but got %v\n", i, content.Entity.JsonMap())
+			}
+		}
+	}
+	if _, err := sr.ReadContent(); err != io.EOF {
+		t.Fatalf("Expect io.EOF, but got %v\n", err)
+	}
+}
+
+func TestStreamShortReads(t *testing.T) {
+	data := javaStream(nodeGraph...)
+	readAll := func(r io.Reader) []interface{} {
+		var values []interface{}
+		sr := NewJavaStreamReader(r)
+		for {
+			content, err := sr.ReadContent()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("ReadContent got %v\n", err)
+			}
+			values = append(values, content.Entity.JsonMap())
+		}
+		if sr.reader.Offset != int64(len(data)) {
+			t.Fatalf("Expect offset %d, but got %d\n", len(data), sr.reader.Offset)
+		}
+		return values
+	}
+	expect := readAll(bytes.NewReader(data))
+	//net.Conn, gzip.Reader etc. may return less bytes than requested
+	for _, r := range []io.Reader{iotest.OneByteReader(bytes.NewReader(data)), iotest.HalfReader(bytes.NewReader(data))} {
+		if got := readAll(r); !reflect.DeepEqual(got, expect) {
+			t.Fatalf("Expect %v, but got %v\n", expect, got)
+		}
+	}
+	//without the buffered reader
+	jo := &JavaTcObject{}
+	if err := jo.Deserialize(iotest.OneByteReader(bytes.NewReader(data[4:])), NewJavaReferencePool(8)); err != nil {
+		t.Fatalf("Deserialize got %v\n", err)
+	}
+	if !reflect.DeepEqual(jo.JsonMap(), expect[0]) {
+		t.Fatalf("Expect %v, but got %v\n", expect[0], jo.JsonMap())
+	}
+	if _, err := ReadNextBytes(iotest.OneByteReader(bytes.NewReader(data[:3])), 4); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+}
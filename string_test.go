@@ -0,0 +1,67 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "errors"
+import "runtime"
+import "strings"
+
+func TestLongString(t *testing.T) {
+	long := strings.Repeat("0123456789", 7000) //70000 bytes
+	data := javaStream(TC_LONGSTRING, []byte{0, 0, 0, 0, 0, 0x01, 0x11, 0x70}, long)
+
+	v, err := DeserializeStream(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	if str, ok := v.JsonMap().(JavaTcString); !ok || string(str) != long {
+		t.Fatalf("Unexpected long string, len %d\n", len(str))
+	}
+	out := new(bytes.Buffer)
+	if err = SerializeJavaEntity(out, NewJavaTcString(long)); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatalf("TC_LONGSTRING serialize mismatch, len %d, %x\n", out.Len(), out.Bytes()[:16])
+	}
+}
+
+func TestLongStringField(t *testing.T) {
+	long := strings.Repeat("a", 1<<16)
+	jo := NewJavaTcObject(1)
+	clz := NewJavaTcClassDesc("com.david.test.serialize.D", 1, SC_SERIALIZABLE)
+	clz.AddField(NewStringJavaField("a", long))
+	clz.AddField(NewStringJavaField("b", "short"))
+	jo.AddClassDesc(clz)
+
+	out := new(bytes.Buffer)
+	if err := SerializeJavaEntity(out, jo); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+	v, err := DeserializeStream(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatalf("DeserializeStream got %v\n", err)
+	}
+	mp := v.JsonMap().(map[string]interface{})
+	if mp["a"] != long || mp["b"] != "short" {
+		t.Fatalf("Unexpected json map, a len %d, b %v\n", len(mp["a"].(string)), mp["b"])
+	}
+}
+
+func TestLongStringTruncated(t *testing.T) {
+	//TC_LONGSTRING of MaxInt32 bytes without data, the string is not allocated before the data
+	data := javaStream(TC_LONGSTRING, []byte{0, 0, 0, 0, 0x7F, 0xFF, 0xFF, 0xFF})
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var js JavaSerializer
+	if err := Unmarshal(data, &js); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+	if err := DecodeBytes(data, &js); !errors.Is(err, ErrTruncated) {
+		t.Fatalf("Expect ErrTruncated, but got %v\n", err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<24 {
+		t.Fatalf("Expect no allocation of the whole string, but got %d bytes\n", allocated)
+	}
+}
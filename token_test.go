@@ -0,0 +1,111 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "io"
+import "errors"
+import "fmt"
+import "strings"
+import "encoding/binary"
+
+func TestTokenReader(t *testing.T) {
+	tr := NewJavaTokenReader(bytes.NewReader(javaStream(nodeGraph...)))
+	var tokens []string
+	for {
+		tok, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Next got %v\n", err)
+		}
+		switch tok.Kind {
+		case TOKEN_BEGIN_OBJECT:
+			tokens = append(tokens, fmt.Sprintf("%s %s 0x%x", tok.Kind, tok.ClassDesc.ClassName, tok.Handle))
+		case TOKEN_REFERENCE:
+			tokens = append(tokens, fmt.Sprintf("%s 0x%x", tok.Kind, tok.Handle))
+		case TOKEN_FIELD:
+			tokens = append(tokens, fmt.Sprintf("%s %s", tok.Kind, tok.Name))
+		case TOKEN_STRING:
+			tokens = append(tokens, fmt.Sprintf("%s %v", tok.Kind, tok.Value))
+		default:
+			tokens = append(tokens, tok.Kind.String())
+		}
+	}
+	expect := []string{
+		"BEGIN_OBJECT Node 0x7e0003", "FIELD next", "REFERENCE 0x7e0003", "FIELD name", "STRING a", "END",
+		"BEGIN_OBJECT Node 0x7e0005", "FIELD next", "REFERENCE 0x7e0003", "FIELD name", "STRING b", "END",
+		"BEGIN_OBJECT Node 0x7e0007", "FIELD next", "NULL", "FIELD name", "REFERENCE 0x7e0004", "END",
+	}
+	if strings.Join(tokens, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("Expect tokens\n%s\nbut got\n%s\n", strings.Join(expect, "\n"), strings.Join(tokens, "\n"))
+	}
+}
+
+type tokenSamples struct {
+	_      struct{} `java:"com.example.Samples"`
+	Tags   []string `java:"tags,list"`
+	Values []int32
+}
+
+func TestTokenReaderSkip(t *testing.T) {
+	samples := &tokenSamples{Tags: []string{"a", "b"}, Values: make([]int32, 1000)}
+	var sum int64
+	for i := range samples.Values {
+		samples.Values[i] = int32(i)
+		sum += int64(i)
+	}
+	data, err := Marshal(samples)
+	if err != nil {
+		t.Fatalf("Marshal got %v\n", err)
+	}
+	tr := NewJavaTokenReader(bytes.NewReader(data))
+	next := func(kind JavaTokenKind) JavaToken {
+		tok, err := tr.Next()
+		if err != nil || tok.Kind != kind {
+			t.Fatalf("Expect %s, but got %s %v\n", kind, tok.Kind, err)
+		}
+		return tok
+	}
+	next(TOKEN_BEGIN_OBJECT)
+	if tok := next(TOKEN_FIELD); tok.Name != "tags" {
+		t.Fatalf("Expect field tags, but got %s\n", tok.Name)
+	}
+	//ArrayList with its writeObject data
+	if err := tr.Skip(); err != nil {
+		t.Fatalf("Skip got %v\n", err)
+	}
+	next(TOKEN_FIELD)
+	if tok := next(TOKEN_BEGIN_ARRAY); tok.Len != 1000 || tok.ClassDesc.ClassName != "[I" {
+		t.Fatalf("Expect [I of 1000, but got %s %d\n", tok.ClassDesc.ClassName, tok.Len)
+	}
+	if tok := next(TOKEN_VALUE); tok.Value != uint32(0) {
+		t.Fatalf("Expect the first element 0, but got %v\n", tok.Value)
+	}
+	var got int64
+	buff := make([]byte, 30)
+	for {
+		n, err := tr.ReadArray(buff)
+		if err == io.EOF {
+			break
+		} else if err != nil || n%4 != 0 {
+			t.Fatalf("ReadArray got %d %v\n", n, err)
+		}
+		for i := 0; i < n; i += 4 {
+			got += int64(int32(binary.BigEndian.Uint32(buff[i:])))
+		}
+	}
+	if got != sum {
+		t.Fatalf("Expect sum %d, but got %d\n", sum, got)
+	}
+	next(TOKEN_END)
+	next(TOKEN_END)
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("Expect io.EOF, but got %v\n", err)
+	}
+
+	tr = NewJavaTokenReader(bytes.NewReader(data[:len(data)-10]))
+	next(TOKEN_BEGIN_OBJECT)
+	if err := tr.Skip(); !errors.Is(err, ErrTruncated) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expect ErrTruncated for truncated stream, but got %v\n", err)
+	}
+}
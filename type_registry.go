@@ -0,0 +1,122 @@
+package javaserializer
+
+import "io"
+import "sort"
+import "sync"
+import "reflect"
+
+//ClassReader read the classdata written by writeObject of the class, i.e. the fields and objectAnnotation till TC_ENDBLOCKDATA
+//the returned JavaSerializer is kept with the object, what ClassWriter needs should be kept in classDesc.RwDatas
+type ClassReader func(reader io.Reader, refs *JavaReferencePool, classDesc *JavaTcClassDesc) (JavaSerializer, error)
+
+//ClassWriter write the classdata of the class, the reverse of ClassReader
+type ClassWriter func(writer io.Writer, refs *JavaReferencePool, classDesc *JavaTcClassDesc) error
+
+//ClassHandler the Go side of a java class
+type ClassHandler struct {
+	SerialVersionUID uint64       //used by Marshal for GoType
+	GoType           reflect.Type //Unmarshal into interface{} creates it, Marshal writes it as this class; nil for none
+	Read             ClassReader  //nil for the generic JavaRwObject
+	Write            ClassWriter  //nil for the generic JavaRwObject
+}
+
+//TypeRegistry Java class name -> the Go side handlers of the class
+//each Decoder or Encoder may use its own registry, DefaultTypeRegistry is used otherwise
+type TypeRegistry struct {
+	lock            sync.RWMutex
+	externalReaders map[string]JavaExternalReader
+	classes         map[string]*ClassHandler
+	goTypes         map[reflect.Type]string //GoType -> class name
+}
+
+//DefaultTypeRegistry the registry used by DeserializeStream, SerializeJavaEntity and the Decoder/Encoder without SetRegistry
+var DefaultTypeRegistry *TypeRegistry
+
+func init() {
+	//the built-in handlers refer to DefaultTypeRegistry, it cannot be a var initializer
+	DefaultTypeRegistry = NewTypeRegistry()
+}
+
+//NewTypeRegistry new type registry with the built-in handlers of HashMap, ArrayList etc.
+func NewTypeRegistry() *TypeRegistry {
+	registry := &TypeRegistry{
+		externalReaders: make(map[string]JavaExternalReader),
+		classes:         make(map[string]*ClassHandler),
+		goTypes:         make(map[reflect.Type]string),
+	}
+	for className, handler := range builtinClassHandlers() {
+		registry.RegisterClass(className, handler)
+	}
+	return registry
+}
+
+//RegisterExternalReader register the reader of Externalizable class, nil to remove it
+//it is required for the streams written by PROTOCOL_VERSION_1
+func (registry *TypeRegistry) RegisterExternalReader(className string, reader JavaExternalReader) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if reader == nil {
+		delete(registry.externalReaders, className)
+	} else {
+		registry.externalReaders[className] = reader
+	}
+}
+
+//LookupExternalReader return the registered reader of the class, nil if not registered
+func (registry *TypeRegistry) LookupExternalReader(className string) JavaExternalReader {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return registry.externalReaders[className]
+}
+
+//RegisterClass register or override the handler of the class, the built-in ones included; nil to remove it
+func (registry *TypeRegistry) RegisterClass(className string, handler *ClassHandler) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if old, ok := registry.classes[className]; ok && old.GoType != nil {
+		delete(registry.goTypes, old.GoType)
+	}
+	if handler == nil {
+		delete(registry.classes, className)
+		return
+	}
+	registry.classes[className] = handler
+	if handler.GoType != nil {
+		registry.goTypes[handler.GoType] = className
+	}
+}
+
+//LookupClass return the registered handler of the class, nil if not registered
+func (registry *TypeRegistry) LookupClass(className string) *ClassHandler {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	return registry.classes[className]
+}
+
+//LookupGoType return the class name and handler registered for Go type t
+func (registry *TypeRegistry) LookupGoType(t reflect.Type) (string, *ClassHandler, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	className, ok := registry.goTypes[t]
+	if !ok {
+		return "", nil, false
+	}
+	return className, registry.classes[className], true
+}
+
+//ClassNames return the sorted names of the registered classes
+func (registry *TypeRegistry) ClassNames() []string {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	names := make([]string, 0, len(registry.classes))
+	for className := range registry.classes {
+		names = append(names, className)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//RegisterClass register the handler of the class to DefaultTypeRegistry
+func RegisterClass(className string, handler *ClassHandler) {
+	DefaultTypeRegistry.RegisterClass(className, handler)
+}
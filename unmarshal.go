@@ -0,0 +1,426 @@
+package javaserializer
+
+import "fmt"
+import "bytes"
+import "reflect"
+import "strings"
+import "unicode/utf8"
+
+// Unmarshal 将反序列化得到的对象图按反射写入Go的值, 规则如下:
+// 	boolean                         -> bool
+// 	byte short int long             -> int 系列(有符号), uint 系列得到原始的二进制补码, float 系列
+// 	float double                    -> float32 float64
+// 	String, char, enum              -> string, enum 为其常量名; char[] 的元素也可以是 int 系列
+// 	Integer Long 等包装类型         -> 与其基本类型相同
+// 	数组, ArrayList, LinkedList     -> slice, array
+// 	HashMap, LinkedHashMap          -> map
+// 	object                          -> struct, 按 `java:"name"` tag 匹配field, 没有tag时按Go的field名忽略大小写匹配,
+// 	                                   匿名嵌入的struct视为父类, 其field与子类在同一层
+// 	                                -> interface{}, 与JsonMap的结果相同; 类在TypeRegistry 中注册了GoType时为该类型的值
+// 	                                -> JavaSerializer, *JavaTcObject 等, 即原始的对象
+// 	null                            -> 零值
+// 同一个java对象在同一类型的指针上只会生成一次, 环形引用也能被还原
+
+//UnmarshalTypeError the java value can not be stored in the go value
+type UnmarshalTypeError struct {
+	Value string       //description of the java value
+	Type  reflect.Type //type of the go value
+	Path  string       //path through the graph, e.g. root.orders[3].customer.name
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("Cannot unmarshal %s into Go value of type %s at %s", e.Value, e.Type, e.Path)
+}
+
+//Unmarshal decode the first content of the java stream data into the value pointed to by v, like encoding/json.Unmarshal
+func Unmarshal(data []byte, v interface{}) error {
+	return NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//DecodeBytes decode the first content of data like Unmarshal, but parse it in place with no per-field allocations, see NewBytesDecoder
+//byte[] stored into *JavaSerializer or interface{} is the sub slice of data, data should not be modified while it is in use
+func DecodeBytes(data []byte, v interface{}) error {
+	return NewBytesDecoder(data).Decode(v)
+}
+
+//unmarshaler state of one Unmarshal
+type unmarshaler struct {
+	strict bool                           //java fields not found in the struct are errors
+	ptrs   map[unmarshalKey]reflect.Value //java object -> the pointer already created
+	types  *TypeRegistry                  //Go types of the java classes stored into interface{}
+}
+
+type unmarshalKey struct {
+	val interface{}
+	typ reflect.Type
+}
+
+//unmarshalEntity store the entity into the value pointed to by v
+func unmarshalEntity(entity JavaSerializer, v interface{}, strict bool, registry *TypeRegistry) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Unmarshal expects a non-nil pointer, but got %T", v)
+	}
+	u := &unmarshaler{
+		strict: strict,
+		ptrs:   make(map[unmarshalKey]reflect.Value),
+		types:  registry,
+	}
+	var val interface{}
+	if entity != nil {
+		val = entity
+		u.ptrs[unmarshalKey{val, rv.Type()}] = rv
+	}
+	return u.value(val, rv.Elem(), "root")
+}
+
+func (u *unmarshaler) typeError(val interface{}, rv reflect.Value, path string) error {
+	desc := fmt.Sprintf("%T", val)
+	switch x := val.(type) {
+	case *JavaTcObject:
+		if len(x.Classes) > 0 {
+			desc = "object " + x.Classes[0].ClassName
+		}
+	case *JavaTcArray:
+		if x.ClassDesc != nil {
+			desc = "array " + x.ClassDesc.ClassName
+		}
+	case *JavaTcEnum:
+		desc = "enum " + x.ConstantName
+	}
+	return &UnmarshalTypeError{
+		Value: desc,
+		Type:  rv.Type(),
+		Path:  path,
+	}
+}
+
+//value store java value val into rv
+func (u *unmarshaler) value(val interface{}, rv reflect.Value, path string) error {
+	if val == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	vt := reflect.TypeOf(val)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if vt.AssignableTo(rv.Type()) {
+			rv.Set(reflect.ValueOf(val))
+			return nil
+		}
+		//同一个java对象只生成一次
+		key := unmarshalKey{val, rv.Type()}
+		cacheable := vt.Kind() == reflect.Ptr
+		if cacheable {
+			if p, ok := u.ptrs[key]; ok {
+				rv.Set(p)
+				return nil
+			}
+		}
+		p := reflect.New(rv.Type().Elem())
+		if cacheable {
+			u.ptrs[key] = p
+		}
+		rv.Set(p)
+		return u.value(val, p.Elem(), path)
+	case reflect.Interface:
+		//注册了GoType的类按该类型生成
+		if t := u.goType(val); t != nil && t.AssignableTo(rv.Type()) {
+			nv := reflect.New(t).Elem()
+			if err := u.value(val, nv, path); err != nil {
+				return err
+			}
+			rv.Set(nv)
+			return nil
+		}
+		if rv.NumMethod() > 0 {
+			if !vt.AssignableTo(rv.Type()) {
+				return u.typeError(val, rv, path)
+			}
+			rv.Set(reflect.ValueOf(val))
+			return nil
+		}
+		if data := newJsonVisitor().value(val, "$"); data != nil {
+			rv.Set(reflect.ValueOf(data))
+		} else {
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+	if vt.AssignableTo(rv.Type()) {
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	//包装类型, String 按其值处理
+	switch x := val.(type) {
+	case *JavaTcString:
+		val = string(*x)
+	case *JavaTcObject:
+		if boxed, ok := boxedValue(x); ok {
+			return u.value(boxed, rv, path)
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if b, ok := val.(bool); ok {
+			rv.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, _, ok := javaInteger(val); ok {
+			if rv.OverflowInt(i) {
+				return u.typeError(val, rv, path)
+			}
+			rv.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if _, bits, ok := javaInteger(val); ok {
+			if rv.OverflowUint(bits) {
+				return u.typeError(val, rv, path)
+			}
+			rv.SetUint(bits)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch f := val.(type) {
+		case float32:
+			rv.SetFloat(float64(f))
+			return nil
+		case float64:
+			rv.SetFloat(f)
+			return nil
+		}
+		if i, _, ok := javaInteger(val); ok {
+			rv.SetFloat(float64(i))
+			return nil
+		}
+	case reflect.String:
+		switch s := val.(type) {
+		case string:
+			rv.SetString(s)
+			return nil
+		case rune:
+			rv.SetString(string(s))
+			return nil
+		case *JavaTcEnum:
+			rv.SetString(s.ConstantName)
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		if arr, ok := val.(*JavaTcArray); ok && arr.Prims != nil {
+			return u.primArray(arr.Prims, rv, path)
+		}
+		if elements, ok := javaElements(val); ok {
+			return u.elements(elements, rv, path)
+		}
+	case reflect.Map:
+		if items, ok := javaMapItems(val); ok {
+			return u.mapItems(items, rv, path)
+		}
+	case reflect.Struct:
+		if jo, ok := val.(*JavaTcObject); ok {
+			return u.object(jo, rv, path)
+		}
+	}
+	return u.typeError(val, rv, path)
+}
+
+//primArray store the typed slice of primitive array into slice or array rv, the slice of the same type is copied in bulk
+func (u *unmarshaler) primArray(prims interface{}, rv reflect.Value, path string) error {
+	pv := reflect.ValueOf(prims)
+	if rv.Kind() == reflect.Slice && pv.Type().AssignableTo(rv.Type()) {
+		rv.Set(reflect.AppendSlice(reflect.MakeSlice(rv.Type(), 0, pv.Len()), pv))
+		return nil
+	}
+	return u.elements(primArrayValues(prims), rv, path)
+}
+
+//elements store the array or list elements into slice or array rv
+func (u *unmarshaler) elements(elements []interface{}, rv reflect.Value, path string) error {
+	if rv.Kind() == reflect.Array {
+		if rv.Len() != len(elements) {
+			return &UnmarshalTypeError{
+				Value: fmt.Sprintf("%d elements", len(elements)),
+				Type:  rv.Type(),
+				Path:  path,
+			}
+		}
+	} else {
+		rv.Set(reflect.MakeSlice(rv.Type(), len(elements), len(elements)))
+	}
+	for i, ele := range elements {
+		if err := u.value(ele, rv.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//mapItems store the key, value, key, value... items into map rv
+func (u *unmarshaler) mapItems(items []interface{}, rv reflect.Value, path string) error {
+	rv.Set(reflect.MakeMapWithSize(rv.Type(), len(items)/2))
+	kt, vt := rv.Type().Key(), rv.Type().Elem()
+	for i := 0; i+1 < len(items); i += 2 {
+		k := reflect.New(kt).Elem()
+		if err := u.value(items[i], k, fmt.Sprintf("%s[key %d]", path, i/2)); err != nil {
+			return err
+		}
+		v := reflect.New(vt).Elem()
+		if err := u.value(items[i+1], v, fmt.Sprintf("%s[%v]", path, k.Interface())); err != nil {
+			return err
+		}
+		rv.SetMapIndex(k, v)
+	}
+	return nil
+}
+
+//goType return the Go type registered for the class of java object val, nil if there is not
+func (u *unmarshaler) goType(val interface{}) reflect.Type {
+	jo, ok := val.(*JavaTcObject)
+	if !ok || len(jo.Classes) == 0 || u.types == nil {
+		return nil
+	}
+	if handler := u.types.LookupClass(jo.Classes[0].ClassName); handler != nil {
+		return handler.GoType
+	}
+	return nil
+}
+
+//object store the field values of java object into struct rv
+func (u *unmarshaler) object(jo *JavaTcObject, rv reflect.Value, path string) error {
+	fields := make(map[string]reflect.Value)
+	collectStructFields(rv, fields)
+	//父类在前, 子类的同名field覆盖父类的
+	for i := len(jo.Classes) - 1; i >= 0; i-- {
+		for _, jf := range jo.Classes[i].Fields {
+			fv, ok := fields[jf.FieldName]
+			if !ok {
+				fv, ok = fields[strings.ToLower(jf.FieldName)]
+			}
+			if !ok {
+				if u.strict {
+					return fmt.Errorf("Unknown field %s of %s in Go value of type %s at %s", jf.FieldName, jo.Classes[i].ClassName, rv.Type(), path)
+				}
+				continue
+			}
+			val := jf.FieldValue
+			//char 读出的是string, 写入整数类型时按rune
+			if s, isStr := val.(string); isStr && jf.FieldType == TC_PRIM_CHAR && fv.Kind() != reflect.String && fv.Kind() != reflect.Interface {
+				val, _ = utf8.DecodeRuneInString(s)
+			}
+			if err := u.value(val, fv, path+"."+jf.FieldName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//collectStructFields java field name -> settable struct field, fields without tag are keyed by lower case name
+//the embedded structs without tag are the super classes
+func collectStructFields(rv reflect.Value, fields map[string]reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		name, _ := parseJavaTag(sf)
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && name == "" {
+			fv := rv.Field(i)
+			if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+				if fv.IsNil() && fv.CanSet() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				collectStructFields(fv, fields)
+				continue
+			}
+		}
+		if sf.PkgPath != "" { //unexported
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		if _, exists := fields[name]; !exists {
+			fields[name] = rv.Field(i)
+		}
+	}
+}
+
+//parseJavaTag return the name and options of the `java:"name,opt..."` tag
+func parseJavaTag(sf reflect.StructField) (string, []string) {
+	tag := sf.Tag.Get("java")
+	if tag == "" {
+		return "", nil
+	}
+	parts := strings.Split(tag, ",")
+	return parts[0], parts[1:]
+}
+
+//boxedValue return the value of Integer, Long etc.
+func boxedValue(jo *JavaTcObject) (interface{}, bool) {
+	if len(jo.Classes) == 0 || len(jo.Classes[0].Fields) == 0 {
+		return nil, false
+	}
+	switch jo.Classes[0].SerialVersionUID {
+	case SID_BYTE, SID_SHORT, SID_BOOLEAN, SID_CHARACTER, SID_INTEGER, SID_LONG, SID_FLOAT, SID_DOUBLE:
+		return jo.Classes[0].Fields[0].FieldValue, true
+	}
+	return nil, false
+}
+
+//javaInteger return the signed value and the two's complement bits of java integral value
+func javaInteger(val interface{}) (int64, uint64, bool) {
+	switch x := val.(type) {
+	case byte:
+		return int64(int8(x)), uint64(x), true
+	case uint16:
+		return int64(int16(x)), uint64(x), true
+	case uint32:
+		return int64(int32(x)), uint64(x), true
+	case uint64:
+		return int64(x), x, true
+	case rune: //char
+		return int64(x), uint64(x), true
+	case int:
+		return int64(x), uint64(x), true
+	}
+	return 0, 0, false
+}
+
+//javaElements return the elements of array, ArrayList or LinkedList
+func javaElements(val interface{}) ([]interface{}, bool) {
+	switch x := val.(type) {
+	case *JavaTcArray:
+		return x.Values, true
+	case *JavaTcObject:
+		for _, js := range x.rwObjects {
+			switch lst := js.(type) {
+			case *JavaArrayList:
+				return lst.Eles, true
+			case *JavaLinkedList:
+				return lst.Eles, true
+			}
+		}
+	}
+	return nil, false
+}
+
+//javaMapItems return the key, value, key, value... items of HashMap or LinkedHashMap
+func javaMapItems(val interface{}) ([]interface{}, bool) {
+	if jo, ok := val.(*JavaTcObject); ok {
+		for _, js := range jo.rwObjects {
+			if mp, ok := js.(*JavaHashMap); ok {
+				return mp.ClassDesc.RwDatas, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,81 @@
+package javaserializer
+
+import "testing"
+import "bytes"
+import "reflect"
+
+type unmarshalOrder struct {
+	ID     int32             `java:"id"`
+	Total  int64             `java:"total"`
+	Name   string            `java:"name"`
+	Price  float64           `java:"price"`
+	Tags   map[string]string `java:"tags"`
+	Data   []byte            `java:"data"`
+	Parent *unmarshalOrder   `java:"parent"`
+}
+
+func TestUnmarshalObject(t *testing.T) {
+	tags, err := NewHashMap(map[string]interface{}{"k": "v"})
+	if err != nil {
+		t.Fatalf("NewHashMap got %v\n", err)
+	}
+	jo := NewJavaTcObject(0x01)
+	cd := NewJavaTcClassDesc("Order", 0x01, SC_SERIALIZABLE)
+	cd.AddField(NewJavaField(TC_PRIM_INTEGER, "id", uint32(0xFFFFFFFF)))
+	cd.AddField(NewJavaField(TC_PRIM_LONG, "total", uint64(10)))
+	cd.AddField(NewStringJavaField("name", "o1"))
+	cd.AddField(NewJavaField(TC_PRIM_DOUBLE, "price", 1.5))
+	cd.AddField(NewObjectJavaField("java.util.HashMap", "tags", tags))
+	cd.AddField(&JavaField{FieldType: TC_OBJ_ARRAY, FieldName: "data", FieldObjectClassName: "[B", FieldValue: NewByteArray([]byte{0x01, 0xFF})})
+	cd.AddField(NewObjectJavaField("Order", "parent", jo))
+	jo.AddClassDesc(cd)
+	out := new(bytes.Buffer)
+	if err := SerializeJavaEntity(out, jo); err != nil {
+		t.Fatalf("SerializeJavaEntity got %v\n", err)
+	}
+
+	var order unmarshalOrder
+	if err := Unmarshal(out.Bytes(), &order); err != nil {
+		t.Fatalf("Unmarshal got %v\n", err)
+	}
+	if order.ID != -1 || order.Total != 10 || order.Name != "o1" || order.Price != 1.5 {
+		t.Fatalf("Unexpected order %+v\n", order)
+	}
+	if !reflect.DeepEqual(order.Tags, map[string]string{"k": "v"}) || !bytes.Equal(order.Data, []byte{0x01, 0xFF}) {
+		t.Fatalf("Unexpected tags %v or data %v\n", order.Tags, order.Data)
+	}
+	if order.Parent != &order {
+		t.Fatalf("Expect parent is the order itself\n")
+	}
+
+	var mismatch struct {
+		Name int `java:"name"`
+	}
+	err = Unmarshal(out.Bytes(), &mismatch)
+	if e, ok := err.(*UnmarshalTypeError); !ok || e.Path != "root.name" {
+		t.Fatalf("Expect UnmarshalTypeError at root.name, but got %v\n", err)
+	}
+}
+
+func TestUnmarshalList(t *testing.T) {
+	//ArrayList ["a", null, "b"]
+	data := javaStream(
+		TC_OBJECT, TC_CLASSDESC, 0x00, 0x13, "java.util.ArrayList", []byte{0x78, 0x81, 0xD2, 0x1D, 0x99, 0xC7, 0x61, 0x9D}, SC_RW_OBJECT, 0x00, 0x01,
+		TC_PRIM_INTEGER, 0x00, 0x04, "size", TC_ENDBLOCKDATA, TC_NULL,
+		[]byte{0x00, 0x00, 0x00, 0x03}, TC_BLOCKDATA, 0x04, []byte{0x00, 0x00, 0x00, 0x03},
+		TC_STRING, 0x00, 0x01, "a", TC_NULL, TC_STRING, 0x00, 0x01, "b", TC_ENDBLOCKDATA,
+	)
+	var strs []*string
+	if err := Unmarshal(data, &strs); err != nil {
+		t.Fatalf("Unmarshal got %v\n", err)
+	}
+	if len(strs) != 3 || *strs[0] != "a" || strs[1] != nil || *strs[2] != "b" {
+		t.Fatalf("Unexpected list %v\n", strs)
+	}
+	var nums []int
+	if err := Unmarshal(data, &nums); err == nil {
+		t.Fatalf("Expect error for []int\n")
+	} else if e, ok := err.(*UnmarshalTypeError); !ok || e.Path != "root[0]" {
+		t.Fatalf("Expect UnmarshalTypeError at root[0], but got %v\n", err)
+	}
+}